@@ -3,19 +3,29 @@ package main
 import (
 	"context"
 	"fmt"
+	nethttp "net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache/rediscache"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/http"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/pubsubconnector"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/pubsubconnector/redisconnector"
 
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/config"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/auth"
+	channelsApi "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/channels"
 	messagesClient "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/messages"
 	sorterApi "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/sorter"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/push"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/router"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/websocket"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services/events"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
 	_ "go.uber.org/automaxprocs"
 )
 
@@ -27,7 +37,57 @@ func main() {
 
 	cache := rediscache.NewCache(redisCacheConnectionConfig)
 
-	wsConnectionsService := services.NewWebsocketConnectionsService(time.Duration(envs.WsReadDeadlineAwaitSeconds)*time.Second, cache)
+	readDeadlineWait := time.Duration(envs.WsReadDeadlineAwaitSeconds) * time.Second
+
+	presence := services.NewPresence(cache, readDeadlineWait+time.Duration(envs.PresenceTTLGraceSeconds)*time.Second)
+
+	wsConnectionsService := services.NewWebsocketConnectionsService(services.WsConnectionsConfig{
+		ReadDeadlineWait:      readDeadlineWait,
+		WriteWait:             time.Duration(envs.WsWriteWaitSeconds) * time.Second,
+		SendQueueSize:         envs.WsSendQueueSize,
+		OverflowPolicy:        envs.WsSendOverflowPolicy,
+		UserDisconnectedGrace: time.Duration(envs.UserDisconnectedGraceSeconds) * time.Second,
+	}, presence)
+
+	redisPubSubConfig := redisconnector.NewConfig(envs.RedisHost, envs.RedisPoolSize)
+	if err := redisPubSubConfig.ValidateConfig(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	pubSubBroker := pubsubconnector.NewPubSubBroker(
+		redisconnector.NewRedisPublisher(redisPubSubConfig),
+		redisconnector.NewRedisSubscriber(redisPubSubConfig),
+	)
+
+	deliveryTracker := services.NewDeliveryTracker(cache, time.Duration(envs.AckTimeoutSeconds)*time.Second, envs.AckMaxRedeliveries, envs.AckMaxPending, time.Duration(envs.AckPendingTTLSeconds)*time.Second)
+
+	offlineInbox := services.NewOfflineInbox(cache, time.Duration(envs.OfflineInboxTTLSeconds)*time.Second, envs.OfflineInboxMaxLength)
+
+	sessionResumer := services.NewSessionResumer(cache, envs.ReplayBufferSize, time.Duration(envs.ReplayBufferTTLSeconds)*time.Second)
+
+	topics := services.NewTopics(pubSubBroker, envs.RedisSubscribeTopic)
+	go topics.Listen(ctx)
+
+	eventDispatcher := services.NewEventDispatcher(wsConnectionsService, presence, deliveryTracker, offlineInbox, sessionResumer, topics, pubSubBroker, envs.RedisSubscribeTopic)
+	go eventDispatcher.Listen(ctx)
+
+	authenticator, err := auth.New(auth.Config{
+		Mode:          envs.AuthMode,
+		TrustedHeader: envs.AuthTrustedHeader,
+		JWT: auth.JWTConfig{
+			Secret:      envs.AuthJwtSecret,
+			JWKSFile:    envs.AuthJwksFile,
+			UserIdClaim: envs.AuthUserIdClaim,
+			QueryParam:  envs.AuthTokenQueryParam,
+			Issuer:      envs.AuthJwtIssuer,
+			Audience:    envs.AuthJwtAudience,
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	messagesHttpClient, err := http.New(http.Config{
 		BaseURL:           envs.MessagesApiUrl,
@@ -51,18 +111,142 @@ func main() {
 
 	messagesApi := messagesClient.New(messagesHttpClient)
 	sorterApi := sorterApi.New(sorterHttpClient)
+	channelsApi := channelsApi.New(messagesHttpClient)
+
+	channelMembership := services.NewChannelMembership(cache, channelsApi, time.Duration(envs.ChannelMembersCacheTTLSeconds)*time.Second)
+
+	presenceNotifier := services.NewPresenceNotifier(cache, presence, eventDispatcher, channelMembership, time.Duration(envs.PresenceOfflineDebounceSeconds)*time.Second, time.Duration(envs.PresenceSubscriptionTTLSeconds)*time.Second)
+
+	topics.Authorize("channel", services.ChannelTopicAuthorizer(channelMembership))
+	for _, topic := range envs.PublicTopics {
+		topics.Authorize(topic, services.AllowTopic)
+	}
+
+	matchmaking := services.NewMatchmaking(cache, sorterApi, channelsApi, eventDispatcher, services.MatchmakingConfig{
+		Timeout:             time.Duration(envs.MatchmakingTimeoutSeconds) * time.Second,
+		Requeue:             envs.MatchmakingRequeue,
+		SearchTimeout:       time.Duration(envs.SearchTimeoutSeconds) * time.Second,
+		SearchRetryInterval: time.Duration(envs.SearchRetryIntervalSeconds) * time.Second,
+	})
+	wsConnectionsService.OnUserDisconnected(matchmaking.Leave)
+
+	messageReceipts := events.NewMessageReceipts(messagesApi, channelMembership, eventDispatcher, cache)
+	wsConnectionsService.OnEventWritten(messageReceipts.Delivered)
+
+	lifecycle := services.NewLifecycle()
+
+	rateLimits, err := services.ParseRateLimits(envs.RateLimits)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	workerPool := events.NewWorkerPool(ctx, lifecycle, events.WorkerPoolConfig{
+		Workers:          envs.WsHandlerWorkers,
+		QueueSize:        envs.WsHandlerQueueSize,
+		MaxTasksPerOwner: envs.WsHandlerMaxEventsPerConn,
+	})
+
+	typingIndicators := events.NewTypingIndicators(
+		eventDispatcher,
+		channelMembership,
+		time.Duration(envs.TypingTimeoutSeconds)*time.Second,
+		time.Duration(envs.TypingThrottleMs)*time.Millisecond,
+	)
 
 	handlers := router.Handlers(ctx,
 		&router.HandlersDependencies{
-			WsConnectionService: wsConnectionsService,
-			MessageSent:         events.NewMessageSent(messagesApi),
-			SearchRequested:     events.NewSearchRequested(sorterApi),
-			ChannelAccepted:     events.NewChannelEvents(domain.CHANNEL_ACCEPTED),
-			ChannelRejected:     events.NewChannelEvents(domain.CHANNEL_REJECTED),
+			Authenticator:        authenticator,
+			ServiceAuthenticator: auth.NewServiceTokenAuthenticator(envs.PushApiTokens),
+			Presence:             presence,
+			WsConnectionService:  wsConnectionsService,
+			EventDispatcher:      eventDispatcher,
+			DeliveryTracker:      deliveryTracker,
+			SessionResumer:       sessionResumer,
+			Lifecycle:            lifecycle,
+			PresenceNotifier:     presenceNotifier,
+			Topics:               topics,
+			RateLimiter:          services.NewRateLimiter(cache, rateLimits),
+			Ack:                  events.NewAck(deliveryTracker),
+			PresenceSubscribe:    events.NewPresenceSubscriptions(domain.PRESENCE_SUBSCRIBE, presenceNotifier),
+			PresenceUnsubscribe:  events.NewPresenceSubscriptions(domain.PRESENCE_UNSUBSCRIBE, presenceNotifier),
+			Subscribe:            events.NewTopicSubscriptions(domain.SUBSCRIBE, topics),
+			Unsubscribe:          events.NewTopicSubscriptions(domain.UNSUBSCRIBE, topics),
+			TypingIndicators:     typingIndicators,
+			MessageRead:          messageReceipts,
+			MessageEdit:          events.NewMessageUpdates(domain.MESSAGE_EDIT_REQUESTED, messagesApi, channelMembership),
+			MessageDelete:        events.NewMessageUpdates(domain.MESSAGE_DELETE_REQUESTED, messagesApi, channelMembership),
+			ReactionAdded:        events.NewMessageReactions(domain.REACTION_ADDED, messagesApi, channelMembership, cache),
+			ReactionRemoved:      events.NewMessageReactions(domain.REACTION_REMOVED, messagesApi, channelMembership, cache),
+			MessageSent:          events.NewMessageSent(messagesApi, channelMembership),
+			SearchRequested:      events.NewSearchRequested(matchmaking),
+			SearchCancelled:      events.NewSearchCancelled(matchmaking),
+			ChannelAccepted:      events.NewChannelEvents(domain.CHANNEL_ACCEPTED, matchmaking),
+			ChannelRejected:      events.NewChannelEvents(domain.CHANNEL_REJECTED, matchmaking),
+			WorkerPool:           workerPool,
+			HandlerTimeout:       time.Duration(envs.WsHandlerTimeoutSeconds) * time.Second,
+			WebsocketConfig: websocket.HandlerConfig{
+				MaxProtocolViolations: envs.WsMaxProtocolViolations,
+				MaxFrameSize:          envs.WsMaxFrameBytes,
+			},
+			PushConfig: push.HandlerConfig{
+				MaxBulkEvents: envs.PushMaxBulkEvents,
+			},
 		},
 	)
-	err = handlers.Run(":" + envs.APIPort)
-	if err != nil {
-		os.Exit(1)
+
+	server := &nethttp.Server{
+		Addr:    ":" + envs.APIPort,
+		Handler: handlers,
+	}
+
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != nethttp.ErrServerClosed {
+			fmt.Println(util.FailedToStartServer, err)
+			os.Exit(1)
+		}
+	}()
+
+	signalCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	<-signalCtx.Done()
+
+	shutdown(ctx, envs, server, lifecycle, workerPool, wsConnectionsService)
+}
+
+// shutdown drains the pod within the configured deadline: it fails readiness and refuses new upgrades,
+// asks every client to reconnect elsewhere, waits for the in-flight events and stops the http server.
+func shutdown(ctx context.Context, envs *config.Environments, server *nethttp.Server, lifecycle *services.Lifecycle, workerPool *events.WorkerPool, wsConnectionsService services.WsConnectionServicer) {
+	fmt.Println(util.ShuttingDown)
+
+	shutdownTimeout := time.Duration(envs.ShutdownTimeoutSeconds) * time.Second
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+
+	lifecycle.StartDraining()
+
+	// The events already read are handled and answered before the connections are closed, leaving
+	// the writers time to flush the answers and the close frames.
+	waitTimeout := shutdownTimeout - time.Duration(envs.WsWriteWaitSeconds)*time.Second
+	if waitTimeout <= 0 {
+		waitTimeout = shutdownTimeout / 2
+	}
+	waitCtx, cancelWait := context.WithTimeout(shutdownCtx, waitTimeout)
+	defer cancelWait()
+
+	wsConnectionsService.StopReading()
+	if err := lifecycle.Wait(waitCtx); err != nil {
+		fmt.Println(util.FailedToWaitInFlightEvents, err)
+		workerPool.Cancel()
+	}
+
+	reconnectJitter := time.Duration(envs.ShutdownReconnectJitterMs) * time.Millisecond
+	wsConnectionsService.CloseAll(shutdownCtx, func() string {
+		return services.ReconnectHint(reconnectJitter)
+	})
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Println(util.FailedToShutdownServer, err)
 	}
 }
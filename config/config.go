@@ -10,14 +10,67 @@ type Environments struct {
 	APIPort string `envconfig:"PORT"`
 	AppName string `envconfig:"APP_NAME"`
 
-	RedisHost                  string `envconfig:"REDIS_HOST"`
-	RedisPoolSize              int    `envconfig:"REDIS_POOL_SIZE"`
-	RedisSubscribeTopic        string `envconfig:"REDIS_SUBSCRIBER_TOPIC"`
-	WsReadDeadlineAwaitSeconds int    `envconfig:"WS_READ_DEADLINE_AWAIT_SECONDS" default:"10"`
+	ShutdownTimeoutSeconds    int `envconfig:"SHUTDOWN_TIMEOUT_SECONDS" default:"25"`
+	ShutdownReconnectJitterMs int `envconfig:"SHUTDOWN_RECONNECT_JITTER_MS" default:"5000"`
+
+	RedisHost                      string `envconfig:"REDIS_HOST"`
+	RedisPoolSize                  int    `envconfig:"REDIS_POOL_SIZE"`
+	RedisSubscribeTopic            string `envconfig:"REDIS_SUBSCRIBER_TOPIC" default:"realtime-handler-events"`
+	WsReadDeadlineAwaitSeconds     int    `envconfig:"WS_READ_DEADLINE_AWAIT_SECONDS" default:"10"`
+	WsWriteWaitSeconds             int    `envconfig:"WS_WRITE_WAIT_SECONDS" default:"10"`
+	PresenceTTLGraceSeconds        int    `envconfig:"PRESENCE_TTL_GRACE_SECONDS" default:"5"`
+	PresenceOfflineDebounceSeconds int    `envconfig:"PRESENCE_OFFLINE_DEBOUNCE_SECONDS" default:"10"`
+	PresenceSubscriptionTTLSeconds int    `envconfig:"PRESENCE_SUBSCRIPTION_TTL_SECONDS" default:"86400"`
+	UserDisconnectedGraceSeconds   int    `envconfig:"USER_DISCONNECTED_GRACE_SECONDS" default:"10"`
+	WsSendQueueSize                int    `envconfig:"WS_SEND_QUEUE_SIZE" default:"64"`
+	WsSendOverflowPolicy           string `envconfig:"WS_SEND_OVERFLOW_POLICY" default:"drop"`
+	WsMaxProtocolViolations        int    `envconfig:"WS_MAX_PROTOCOL_VIOLATIONS" default:"5"`
+	WsMaxFrameBytes                int64  `envconfig:"WS_MAX_FRAME_BYTES" default:"4096"`
+	WsHandlerTimeoutSeconds        int    `envconfig:"WS_HANDLER_TIMEOUT_SECONDS" default:"30"`
+	WsHandlerWorkers               int    `envconfig:"WS_HANDLER_WORKERS" default:"64"`
+	WsHandlerQueueSize             int    `envconfig:"WS_HANDLER_QUEUE_SIZE" default:"4096"`
+	WsHandlerMaxEventsPerConn      int    `envconfig:"WS_HANDLER_MAX_EVENTS_PER_CONN" default:"32"`
+	AckTimeoutSeconds              int    `envconfig:"ACK_TIMEOUT_SECONDS" default:"10"`
+	AckMaxRedeliveries             int    `envconfig:"ACK_MAX_REDELIVERIES" default:"5"`
+	AckMaxPending                  int64  `envconfig:"ACK_MAX_PENDING" default:"500"`
+	AckPendingTTLSeconds           int    `envconfig:"ACK_PENDING_TTL_SECONDS" default:"86400"`
+	OfflineInboxTTLSeconds         int    `envconfig:"OFFLINE_INBOX_TTL_SECONDS" default:"604800"`
+	OfflineInboxMaxLength          int64  `envconfig:"OFFLINE_INBOX_MAX_LENGTH" default:"100"`
+	ReplayBufferSize               int64  `envconfig:"REPLAY_BUFFER_SIZE" default:"200"`
+	ReplayBufferTTLSeconds         int    `envconfig:"REPLAY_BUFFER_TTL_SECONDS" default:"300"`
+
+	AuthMode            string `envconfig:"AUTH_MODE" default:"jwt"`
+	AuthTrustedHeader   string `envconfig:"AUTH_TRUSTED_HEADER" default:"user_id"`
+	AuthJwtSecret       string `envconfig:"AUTH_JWT_SECRET"`
+	AuthJwksFile        string `envconfig:"AUTH_JWKS_FILE"`
+	AuthUserIdClaim     string `envconfig:"AUTH_USER_ID_CLAIM" default:"sub"`
+	AuthTokenQueryParam string `envconfig:"AUTH_TOKEN_QUERY_PARAM" default:"access_token"`
+	AuthJwtIssuer       string `envconfig:"AUTH_JWT_ISSUER"`
+	AuthJwtAudience     string `envconfig:"AUTH_JWT_AUDIENCE"`
+
+	// PushApiTokens maps each internal service allowed to push events to its bearer token, e.g. "messages:token1,sorter:token2".
+	PushApiTokens     map[string]string `envconfig:"PUSH_API_TOKENS"`
+	PushMaxBulkEvents int               `envconfig:"PUSH_MAX_BULK_EVENTS" default:"500"`
+
+	// RateLimits is the limit of each event type a user can send, as "<limit>/<window>", e.g. "MESSAGE_SENT:30/10s".
+	RateLimits map[string]string `envconfig:"RATE_LIMITS" default:"MESSAGE_SENT:30/10s,SEARCH_REQUESTED:5/1m,REACTION_ADDED:30/10s,TYPING_STARTED:30/10s"`
+
+	TypingTimeoutSeconds int `envconfig:"TYPING_TIMEOUT_SECONDS" default:"5"`
+	TypingThrottleMs     int `envconfig:"TYPING_THROTTLE_MS" default:"2000"`
 
 	MessagesApiUrl string `envconfig:"MESSAGES_API_URL"`
 
+	ChannelMembersCacheTTLSeconds int `envconfig:"CHANNEL_MEMBERS_CACHE_TTL_SECONDS" default:"30"`
+
+	// PublicTopics can be subscribed to by every user, "channel:<id>" topics are restricted to the channel members.
+	PublicTopics []string `envconfig:"PUBLIC_TOPICS" default:"announcements"`
+
 	SorterApiUrl string `envconfig:"SORTER_API_URL"`
+
+	MatchmakingTimeoutSeconds  int  `envconfig:"MATCHMAKING_TIMEOUT_SECONDS" default:"60"`
+	MatchmakingRequeue         bool `envconfig:"MATCHMAKING_REQUEUE" default:"false"`
+	SearchTimeoutSeconds       int  `envconfig:"SEARCH_TIMEOUT_SECONDS" default:"120"`
+	SearchRetryIntervalSeconds int  `envconfig:"SEARCH_RETRY_INTERVAL_SECONDS" default:"5"`
 }
 
 // LoadEnvVars load the environment variables
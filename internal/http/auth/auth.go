@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ModeJWT validates a bearer JWT sent by the client.
+	ModeJWT = "jwt"
+	// ModeTrustedGateway trusts the user id set in a header by a gateway in front of the service.
+	// It must only be enabled in environments where clients can't reach the service directly.
+	ModeTrustedGateway = "trusted_gateway"
+
+	// SubprotocolBearer is the Sec-WebSocket-Protocol value that precedes the token, e.g. "bearer, <token>".
+	SubprotocolBearer = "bearer"
+
+	defaultUserIdClaim   = "sub"
+	defaultQueryParam    = "access_token"
+	defaultTrustedHeader = "user_id"
+)
+
+var (
+	ErrMissingCredentials = errors.New("auth: missing credentials")
+	ErrInvalidToken       = errors.New("auth: invalid token")
+	ErrUnknownMode        = errors.New("auth: unknown authentication mode")
+)
+
+// Identity is the authenticated caller of a websocket upgrade.
+type Identity struct {
+	UserId string
+	// Subprotocol must be echoed back on the upgrade when the token was sent through Sec-WebSocket-Protocol.
+	Subprotocol string
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+type Config struct {
+	Mode          string
+	TrustedHeader string
+	JWT           JWTConfig
+}
+
+// New builds the authenticator for the configured mode.
+func New(config Config) (Authenticator, error) {
+	switch config.Mode {
+	case ModeJWT:
+		return NewJWTAuthenticator(config.JWT)
+	case ModeTrustedGateway:
+		return NewTrustedGatewayAuthenticator(config.TrustedHeader), nil
+	default:
+		return nil, ErrUnknownMode
+	}
+}
+
+type trustedGatewayAuthenticator struct {
+	header string
+}
+
+func NewTrustedGatewayAuthenticator(header string) Authenticator {
+	if header == "" {
+		header = defaultTrustedHeader
+	}
+	return &trustedGatewayAuthenticator{header}
+}
+
+func (a *trustedGatewayAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	userId := r.Header.Get(a.header)
+	if userId == "" {
+		return nil, ErrMissingCredentials
+	}
+	return &Identity{UserId: userId}, nil
+}
+
+// extractToken looks for the token in the Authorization header, then in the Sec-WebSocket-Protocol
+// header and finally in the query string, since browsers can't set headers on websocket requests.
+func extractToken(r *http.Request, queryParam string) (token string, subprotocol string) {
+	authorization := r.Header.Get("Authorization")
+	if scheme, value, ok := strings.Cut(authorization, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(value), ""
+	}
+
+	protocols := websocketProtocols(r)
+	for i, protocol := range protocols {
+		if strings.EqualFold(protocol, SubprotocolBearer) && i+1 < len(protocols) {
+			return protocols[i+1], protocol
+		}
+	}
+
+	return r.URL.Query().Get(queryParam), ""
+}
+
+func websocketProtocols(r *http.Request) []string {
+	protocols := make([]string, 0)
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
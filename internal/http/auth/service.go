@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+type serviceTokenAuthenticator struct {
+	tokens map[string]string
+}
+
+// NewServiceTokenAuthenticator authenticates the internal services calling the HTTP API. Tokens maps
+// each service name to its static bearer token, and the service name becomes the identity's UserId.
+func NewServiceTokenAuthenticator(tokens map[string]string) Authenticator {
+	return &serviceTokenAuthenticator{tokens}
+}
+
+func (a *serviceTokenAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrMissingCredentials
+	}
+	token = strings.TrimSpace(token)
+
+	for service, serviceToken := range a.tokens {
+		if serviceToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(serviceToken)) == 1 {
+			return &Identity{UserId: service}, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
@@ -0,0 +1,75 @@
+package channelsApi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/http"
+)
+
+type ChannelsApi interface {
+	CreateChannel(ctx context.Context, channelRequest domain.ChannelRequest, headers map[string]string) (*domain.Channel, error)
+	GetMembers(ctx context.Context, channelId string, headers map[string]string) (*domain.Channel, error)
+}
+
+type channelsApi struct {
+	channelsClient http.HttpClienter
+}
+
+func New(channelsClient http.HttpClienter) ChannelsApi {
+	return &channelsApi{
+		channelsClient: channelsClient,
+	}
+}
+
+func (c *channelsApi) CreateChannel(ctx context.Context, channelRequest domain.ChannelRequest, headers map[string]string) (*domain.Channel, error) {
+	requestPayload, err := json.Marshal(channelRequest)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.channelsClient.Post(ctx, http.ClientConfig{
+		Endpoint: "/v1/channels",
+		Headers:  headers,
+	}, requestPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 && response.StatusCode != 201 {
+		return nil, fmt.Errorf("error creating channel: %s", response.Body)
+	}
+
+	var channel *domain.Channel
+	err = json.Unmarshal(response.Body, &channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+func (c *channelsApi) GetMembers(ctx context.Context, channelId string, headers map[string]string) (*domain.Channel, error) {
+	url := fmt.Sprintf("/v1/channels/%s/members", neturl.PathEscape(channelId))
+	response, err := c.channelsClient.Get(ctx, http.ClientConfig{
+		Endpoint: url,
+		Headers:  headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("error getting channel members: %s", response.Body)
+	}
+
+	var channel *domain.Channel
+	err = json.Unmarshal(response.Body, &channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return channel, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	neturl "net/url"
 
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/http"
@@ -11,6 +12,12 @@ import (
 
 type MessagesApi interface {
 	CreateMessage(ctx context.Context, messageRequest domain.MessageRequest, headers map[string]string) (*domain.MessageCreated, error)
+	GetMessage(ctx context.Context, channelId string, messageId string, headers map[string]string) (*domain.MessageCreated, error)
+	UpdateMessage(ctx context.Context, messageUpdateRequest domain.MessageUpdateRequest, headers map[string]string) (*domain.MessageCreated, error)
+	DeleteMessage(ctx context.Context, channelId string, messageId string, headers map[string]string) error
+	AddReaction(ctx context.Context, reactionRequest domain.ReactionRequest, headers map[string]string) (*domain.ReactionSummary, error)
+	RemoveReaction(ctx context.Context, reactionRequest domain.ReactionRequest, headers map[string]string) (*domain.ReactionSummary, error)
+	UpdateReceipts(ctx context.Context, receiptRequest domain.ReceiptRequest, headers map[string]string) ([]domain.MessageReceipt, error)
 }
 
 type messagesApi struct {
@@ -28,7 +35,7 @@ func (m *messagesApi) CreateMessage(ctx context.Context, messageRequest domain.M
 	if err != nil {
 		return nil, err
 	}
-	url := fmt.Sprintf("/v1/channels/%s/messages", messageRequest.ChannelId)
+	url := fmt.Sprintf("/v1/channels/%s/messages", neturl.PathEscape(messageRequest.ChannelId))
 	response, err := m.messagesClient.Post(ctx, http.ClientConfig{
 		Endpoint: url,
 		Headers:  headers,
@@ -49,3 +56,159 @@ func (m *messagesApi) CreateMessage(ctx context.Context, messageRequest domain.M
 
 	return message, nil
 }
+
+func (m *messagesApi) GetMessage(ctx context.Context, channelId string, messageId string, headers map[string]string) (*domain.MessageCreated, error) {
+	url := fmt.Sprintf("/v1/channels/%s/messages/%s", neturl.PathEscape(channelId), neturl.PathEscape(messageId))
+	response, err := m.messagesClient.Get(ctx, http.ClientConfig{
+		Endpoint: url,
+		Headers:  headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("error getting message: %s", response.Body)
+	}
+
+	var message *domain.MessageCreated
+	err = json.Unmarshal(response.Body, &message)
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers check the sender and the channel of the message, a null body must not reach them.
+	if message == nil {
+		return nil, fmt.Errorf("error getting message: empty response")
+	}
+
+	return message, nil
+}
+
+func (m *messagesApi) UpdateMessage(ctx context.Context, messageUpdateRequest domain.MessageUpdateRequest, headers map[string]string) (*domain.MessageCreated, error) {
+	requestPayload, err := json.Marshal(messageUpdateRequest)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("/v1/channels/%s/messages/%s", neturl.PathEscape(messageUpdateRequest.ChannelId), neturl.PathEscape(messageUpdateRequest.MessageId))
+	response, err := m.messagesClient.Patch(ctx, http.ClientConfig{
+		Endpoint: url,
+		Headers:  headers,
+	}, requestPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("error updating message: %s", response.Body)
+	}
+
+	var message *domain.MessageCreated
+	err = json.Unmarshal(response.Body, &message)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+func (m *messagesApi) DeleteMessage(ctx context.Context, channelId string, messageId string, headers map[string]string) error {
+	url := fmt.Sprintf("/v1/channels/%s/messages/%s", neturl.PathEscape(channelId), neturl.PathEscape(messageId))
+	response, err := m.messagesClient.Delete(ctx, http.ClientConfig{
+		Endpoint: url,
+		Headers:  headers,
+	})
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != 200 && response.StatusCode != 204 {
+		return fmt.Errorf("error deleting message: %s", response.Body)
+	}
+
+	return nil
+}
+
+func (m *messagesApi) AddReaction(ctx context.Context, reactionRequest domain.ReactionRequest, headers map[string]string) (*domain.ReactionSummary, error) {
+	requestPayload, err := json.Marshal(reactionRequest)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("/v1/channels/%s/messages/%s/reactions", neturl.PathEscape(reactionRequest.ChannelId), neturl.PathEscape(reactionRequest.MessageId))
+	response, err := m.messagesClient.Post(ctx, http.ClientConfig{
+		Endpoint: url,
+		Headers:  headers,
+	}, requestPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 && response.StatusCode != 201 {
+		return nil, fmt.Errorf("error adding reaction: %s", response.Body)
+	}
+
+	var summary *domain.ReactionSummary
+	err = json.Unmarshal(response.Body, &summary)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+func (m *messagesApi) RemoveReaction(ctx context.Context, reactionRequest domain.ReactionRequest, headers map[string]string) (*domain.ReactionSummary, error) {
+	url := fmt.Sprintf("/v1/channels/%s/messages/%s/reactions/%s?user_id=%s",
+		neturl.PathEscape(reactionRequest.ChannelId),
+		neturl.PathEscape(reactionRequest.MessageId),
+		neturl.PathEscape(reactionRequest.Emoji),
+		neturl.QueryEscape(reactionRequest.UserId),
+	)
+	response, err := m.messagesClient.Delete(ctx, http.ClientConfig{
+		Endpoint: url,
+		Headers:  headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("error removing reaction: %s", response.Body)
+	}
+
+	var summary *domain.ReactionSummary
+	err = json.Unmarshal(response.Body, &summary)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// UpdateReceipts records that the user got or read a message, or every message up to ReadUpTo,
+// and returns the receipts of the affected messages.
+func (m *messagesApi) UpdateReceipts(ctx context.Context, receiptRequest domain.ReceiptRequest, headers map[string]string) ([]domain.MessageReceipt, error) {
+	requestPayload, err := json.Marshal(receiptRequest)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("/v1/channels/%s/receipts", neturl.PathEscape(receiptRequest.ChannelId))
+	response, err := m.messagesClient.Post(ctx, http.ClientConfig{
+		Endpoint: url,
+		Headers:  headers,
+	}, requestPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("error updating receipts: %s", response.Body)
+	}
+
+	var receipts []domain.MessageReceipt
+	err = json.Unmarshal(response.Body, &receipts)
+	if err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	neturl "net/url"
 
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/http"
@@ -24,7 +25,7 @@ func New(sorterClient http.HttpClienter) SorterApi {
 }
 
 func (s *sorterApi) Sort(ctx context.Context, userId string) (*domain.SortResponse, error) {
-	url := fmt.Sprintf("/v1/search?user_id=%s", userId)
+	url := fmt.Sprintf("/v1/search?user_id=%s", neturl.QueryEscape(userId))
 	response, err := s.sorterClient.Get(ctx, http.ClientConfig{
 		Endpoint: url,
 	})
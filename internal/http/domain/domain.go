@@ -34,10 +34,16 @@ func (EventReceived *EventReceived) ToEventToPublish(userId string) *EventToPubl
 }
 
 type EventToPublish struct {
-	Event   string      `json:"event"`
-	EventId string      `json:"event_id"`
-	UserId  string      `json:"user_id"`
-	Data    interface{} `json:"data"`
+	Event      string      `json:"event"`
+	EventId    string      `json:"event_id"`
+	UserId     string      `json:"user_id"`
+	DeliveryId string      `json:"delivery_id,omitempty"`
+	Seq        int64       `json:"seq,omitempty"`
+	Topic      string      `json:"topic,omitempty"`
+	Data       interface{} `json:"data"`
+	// Ephemeral events are only written to connected users: they are not numbered,
+	// acknowledged nor kept in the offline inbox.
+	Ephemeral bool `json:"-"`
 }
 
 type EventSubscribed struct {
@@ -128,13 +134,139 @@ type MessageCreated struct {
 
 func (messageCreated *MessageCreated) ParseMessageCreatedToEventToPublish(receiverId string, eventId string) *EventToPublish {
 	return &EventToPublish{
-		Event:   "MESSAGE_RECEIVED",
+		Event:   MESSAGE_RECEIVED,
 		EventId: eventId,
 		UserId:  receiverId,
 		Data:    messageCreated,
 	}
 }
 
+type MessageUpdateReceived struct {
+	Event   string        `json:"event"`
+	EventId string        `json:"event_id"`
+	UserId  string        `json:"user_id"`
+	Data    MessageUpdate `json:"data"`
+}
+
+type MessageUpdate struct {
+	Channel   *Channel `json:"channel"`
+	MessageId string   `json:"message_id"`
+	Message   string   `json:"message"`
+	Data      string   `json:"data"`
+}
+
+type MessageUpdateRequest struct {
+	ChannelId string `param:"channel_id"`
+	MessageId string `param:"message_id"`
+	Message   string `json:"message"`
+	Data      string `json:"data"`
+}
+
+type MessageDeleted struct {
+	MessageId string `json:"message_id"`
+	ChannelId string `json:"channel_id"`
+}
+
+type ReactionReceived struct {
+	Event   string   `json:"event"`
+	EventId string   `json:"event_id"`
+	UserId  string   `json:"user_id"`
+	Data    Reaction `json:"data"`
+}
+
+type Reaction struct {
+	Channel   *Channel `json:"channel"`
+	MessageId string   `json:"message_id"`
+	Emoji     string   `json:"emoji"`
+}
+
+type ReactionRequest struct {
+	ChannelId string `param:"channel_id"`
+	MessageId string `param:"message_id"`
+	UserId    string `json:"user_id"`
+	Emoji     string `json:"emoji"`
+}
+
+type ReactionSummary struct {
+	MessageId string          `json:"message_id"`
+	ChannelId string          `json:"channel_id"`
+	Reactions []ReactionCount `json:"reactions"`
+}
+
+type ReactionCount struct {
+	Emoji   string   `json:"emoji"`
+	Count   int      `json:"count"`
+	UserIds []string `json:"user_ids"`
+}
+
+type ReactionChanged struct {
+	MessageId string          `json:"message_id"`
+	ChannelId string          `json:"channel_id"`
+	UserId    string          `json:"user_id"`
+	Emoji     string          `json:"emoji"`
+	Reactions []ReactionCount `json:"reactions"`
+}
+
+type MessageReadReceived struct {
+	Event   string      `json:"event"`
+	EventId string      `json:"event_id"`
+	UserId  string      `json:"user_id"`
+	Data    MessageRead `json:"data"`
+}
+
+type MessageRead struct {
+	ChannelId string     `json:"channel_id"`
+	MessageId string     `json:"message_id"`
+	ReadUpTo  *time.Time `json:"read_up_to,omitempty"`
+}
+
+type ReceiptRequest struct {
+	ChannelId string     `param:"channel_id"`
+	UserId    string     `json:"user_id"`
+	Status    string     `json:"status"`
+	MessageId string     `json:"message_id,omitempty"`
+	ReadUpTo  *time.Time `json:"read_up_to,omitempty"`
+}
+
+type MessageReceipt struct {
+	MessageId string    `json:"message_id"`
+	ChannelId string    `json:"channel_id"`
+	SenderId  string    `json:"sender_id"`
+	UserId    string    `json:"user_id"`
+	Status    string    `json:"status"`
+	At        time.Time `json:"at"`
+}
+
+func (receipt *MessageReceipt) ParseMessageReceiptToEventToPublish(eventId string) *EventToPublish {
+	event := MESSAGE_DELIVERED
+	if receipt.Status == RECEIPT_STATUS_READ {
+		event = MESSAGE_READ
+	}
+
+	return &EventToPublish{
+		Event:   event,
+		EventId: eventId,
+		UserId:  receipt.SenderId,
+		Data:    receipt,
+	}
+}
+
+type TypingReceived struct {
+	Event   string     `json:"event"`
+	EventId string     `json:"event_id"`
+	UserId  string     `json:"user_id"`
+	Data    TypingData `json:"data"`
+}
+
+type TypingData struct {
+	Channel *Channel `json:"channel"`
+}
+
+type TypingChanged struct {
+	ChannelId string `json:"channel_id"`
+	UserId    string `json:"user_id"`
+}
+
 type SortResponse struct {
 	Users      []User   `json:"users"`
 	Categories []string `json:"categories"`
@@ -148,20 +280,191 @@ type User struct {
 }
 
 type SearchRequested struct {
-	Event  string `json:"event"`
+	Event   string `json:"event"`
+	EventId string `json:"event_id"`
+	UserId  string `json:"user_id"`
+}
+
+type ChannelProposal struct {
+	ProposalId string    `json:"proposal_id"`
+	Users      []User    `json:"users"`
+	Categories []string  `json:"categories"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type ChannelResponseReceived struct {
+	Event   string          `json:"event"`
+	EventId string          `json:"event_id"`
+	UserId  string          `json:"user_id"`
+	Data    ChannelResponse `json:"data"`
+}
+
+type ChannelResponse struct {
+	ProposalId string `json:"proposal_id"`
+}
+
+type ChannelResponded struct {
+	ProposalId string `json:"proposal_id"`
+	UserId     string `json:"user_id"`
+}
+
+type ChannelRequest struct {
+	Members    []string `json:"members"`
+	Categories []string `json:"categories"`
+}
+
+type ChannelCreated struct {
+	ProposalId string   `json:"proposal_id"`
+	ChannelId  string   `json:"channel_id"`
+	Members    []string `json:"members"`
+	Categories []string `json:"categories"`
+}
+
+type ChannelCancelled struct {
+	ProposalId string `json:"proposal_id"`
+	Reason     string `json:"reason"`
+	RejectedBy string `json:"rejected_by,omitempty"`
+	Requeued   bool   `json:"requeued"`
+}
+
+// PushEventRequest is an event pushed to a user by another service through the HTTP API.
+type PushEventRequest struct {
+	UserId    string      `json:"user_id"`
+	Event     string      `json:"event"`
+	EventId   string      `json:"event_id"`
+	Data      interface{} `json:"data"`
+	Ephemeral bool        `json:"ephemeral"`
+}
+
+func (p *PushEventRequest) Validate() error {
+	if p.UserId == "" {
+		return errors.New("user_id is required")
+	}
+
+	if p.Event == "" {
+		return errors.New("event is required")
+	}
+
+	return nil
+}
+
+func (p *PushEventRequest) ToEventToPublish() *EventToPublish {
+	return &EventToPublish{
+		Event:     p.Event,
+		EventId:   p.EventId,
+		UserId:    p.UserId,
+		Data:      p.Data,
+		Ephemeral: p.Ephemeral,
+	}
+}
+
+type BulkPushEventRequest struct {
+	Events []PushEventRequest `json:"events"`
+}
+
+type PushEventResult struct {
 	UserId string `json:"user_id"`
+	Online bool   `json:"online"`
 }
 
-type ChannelEvents struct {
-	Event string   `json:"event"`
-	Users []string `json:"users"`
+type TopicSubscriptionReceived struct {
+	Event   string            `json:"event"`
+	EventId string            `json:"event_id"`
+	UserId  string            `json:"user_id"`
+	Data    TopicSubscription `json:"data"`
+}
+
+type TopicSubscription struct {
+	Topics []string `json:"topics"`
+}
+
+type TopicEventRequest struct {
+	Event   string      `json:"event"`
+	EventId string      `json:"event_id"`
+	Data    interface{} `json:"data"`
+}
+
+type AckReceived struct {
+	Event   string `json:"event"`
+	EventId string `json:"event_id"`
+	UserId  string `json:"user_id"`
+	Data    Ack    `json:"data"`
+}
+
+type Ack struct {
+	DeliveryIds []string `json:"delivery_ids"`
+}
+
+type SessionStarted struct {
+	ResumeToken    string `json:"resume_token"`
+	LastSeq        int64  `json:"last_seq"`
+	Resumed        bool   `json:"resumed"`
+	ResyncRequired bool   `json:"resync_required"`
+}
+
+type PresenceSubscriptionReceived struct {
+	Event   string               `json:"event"`
+	EventId string               `json:"event_id"`
+	UserId  string               `json:"user_id"`
+	Data    PresenceSubscription `json:"data"`
+}
+
+type PresenceSubscription struct {
+	// ChannelId is the channel shared with the users, only the presence of its members can be subscribed to.
+	ChannelId string   `json:"channel_id,omitempty"`
+	UserIds   []string `json:"user_ids"`
+}
+
+type PresenceChanged struct {
+	UserId string    `json:"user_id"`
+	At     time.Time `json:"at"`
 }
 
 const (
-	SEARCH_REQUESTED = "SEARCH_REQUESTED"
-	CHANNEL_ACCEPTED = "CHANNEL_ACCEPTED"
-	CHANNEL_REJECTED = "CHANNEL_REJECTED"
-	CHANNEL_FOUND    = "CHANNEL_FOUND"
+	ACK             = "ACK"
+	SESSION_STARTED = "SESSION_STARTED"
+
+	SUBSCRIBE    = "SUBSCRIBE"
+	UNSUBSCRIBE  = "UNSUBSCRIBE"
+	SUBSCRIBED   = "SUBSCRIBED"
+	UNSUBSCRIBED = "UNSUBSCRIBED"
+
+	PRESENCE_SUBSCRIBE   = "PRESENCE_SUBSCRIBE"
+	PRESENCE_UNSUBSCRIBE = "PRESENCE_UNSUBSCRIBE"
+	USER_ONLINE          = "USER_ONLINE"
+	USER_OFFLINE         = "USER_OFFLINE"
+
+	TYPING_STARTED = "TYPING_STARTED"
+	TYPING_STOPPED = "TYPING_STOPPED"
+
+	MESSAGE_SENT      = "MESSAGE_SENT"
+	MESSAGE_RECEIVED  = "MESSAGE_RECEIVED"
+	MESSAGE_DELIVERED = "MESSAGE_DELIVERED"
+	MESSAGE_READ      = "MESSAGE_READ"
+
+	MESSAGE_EDIT_REQUESTED   = "MESSAGE_EDIT_REQUESTED"
+	MESSAGE_DELETE_REQUESTED = "MESSAGE_DELETE_REQUESTED"
+	MESSAGE_EDITED           = "MESSAGE_EDITED"
+	MESSAGE_DELETED          = "MESSAGE_DELETED"
+
+	REACTION_ADDED   = "REACTION_ADDED"
+	REACTION_REMOVED = "REACTION_REMOVED"
+
+	RECEIPT_STATUS_DELIVERED = "delivered"
+	RECEIPT_STATUS_READ      = "read"
+
+	SEARCH_REQUESTED  = "SEARCH_REQUESTED"
+	SEARCH_CANCELLED  = "SEARCH_CANCELLED"
+	SEARCH_TIMED_OUT  = "SEARCH_TIMED_OUT"
+	CHANNEL_ACCEPTED  = "CHANNEL_ACCEPTED"
+	CHANNEL_REJECTED  = "CHANNEL_REJECTED"
+	CHANNEL_FOUND     = "CHANNEL_FOUND"
+	CHANNEL_CREATED   = "CHANNEL_CREATED"
+	CHANNEL_CANCELLED = "CHANNEL_CANCELLED"
+
+	CANCEL_REASON_REJECTED = "rejected"
+	CANCEL_REASON_TIMEOUT  = "timeout"
+	CANCEL_REASON_FAILED   = "failed"
 )
 
 /*
@@ -175,15 +478,39 @@ user_id: "123"
 {
 event: CHANNEL_FOUND,
 data: {
+	"proposal_id": "abc",
 	"users": len(4),
-	"categories": ["category1", "category2"]
+	"categories": ["category1", "category2"],
+	"expires_at": "2024-01-01T00:00:00Z"
 }
 }
 
 1..*
 {
 event: CHANNEL_ACCEPTED | CHANNEL_REJECTED
-user_id: "123"
+data: {
+	"proposal_id": "abc"
+}
+}
+
+// saida, quando todos aceitam
+{
+event: CHANNEL_CREATED,
+data: {
+	"proposal_id": "abc",
+	"channel_id": "456",
+	"members": ["123", ...]
+}
+}
+
+// saida, quando alguem rejeita ou o prazo expira
+{
+event: CHANNEL_CANCELLED,
+data: {
+	"proposal_id": "abc",
+	"reason": "rejected" | "timeout" | "failed",
+	"requeued": true
+}
 }
 
 */
@@ -0,0 +1,130 @@
+package domain
+
+import "time"
+
+const ERROR = "ERROR"
+
+// Error codes sent to clients in the data of ERROR frames. They are part of the
+// public protocol, so existing values must never change.
+const (
+	ErrorCodeInvalidJson           = "invalid_json"
+	ErrorCodeValidationFailed      = "validation_failed"
+	ErrorCodeUnknownEvent          = "unknown_event"
+	ErrorCodeForbidden             = "forbidden"
+	ErrorCodeConflict              = "conflict"
+	ErrorCodeHandlerFailed         = "handler_failed"
+	ErrorCodeDownstreamUnavailable = "downstream_unavailable"
+	ErrorCodeInternal              = "internal_error"
+	ErrorCodeOverloaded            = "overloaded"
+	ErrorCodeRateLimited           = "rate_limited"
+	ErrorCodeFrameTooLarge         = "frame_too_large"
+	ErrorCodeCancelled             = "cancelled"
+)
+
+type EventError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Event   string       `json:"event,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError tells why a field of the event doesn't match its schema. Field is the dotted
+// path of the field, e.g. "data.channel.channel_id", empty for the whole event.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NewErrorEvent builds the ERROR frame answering the event eventId sent by userId.
+// It is ephemeral, a client that went away meanwhile doesn't get it later.
+func NewErrorEvent(userId string, eventId string, eventType string, code string, err error) *EventToPublish {
+	return &EventToPublish{
+		Event:   ERROR,
+		EventId: eventId,
+		UserId:  userId,
+		Data: EventError{
+			Code:    code,
+			Message: err.Error(),
+			Event:   eventType,
+		},
+		Ephemeral: true,
+	}
+}
+
+// NewSchemaErrorEvent builds the validation_failed ERROR frame of an event not matching its schema.
+func NewSchemaErrorEvent(userId string, eventId string, eventType string, fields []FieldError) *EventToPublish {
+	return &EventToPublish{
+		Event:   ERROR,
+		EventId: eventId,
+		UserId:  userId,
+		Data: EventError{
+			Code:    ErrorCodeValidationFailed,
+			Message: "event does not match its schema",
+			Event:   eventType,
+			Fields:  fields,
+		},
+		Ephemeral: true,
+	}
+}
+
+// EVENT_ACCEPTED and EVENT_FAILED answer the sender of an inbound event with the outcome
+// of its handling, carrying the event_id it was sent with. RATE_LIMITED replaces EVENT_FAILED
+// when the event was refused by the rate limit, its retry_after_ms telling when to send it again.
+const (
+	EVENT_ACCEPTED = "EVENT_ACCEPTED"
+	EVENT_FAILED   = "EVENT_FAILED"
+	RATE_LIMITED   = "RATE_LIMITED"
+)
+
+type EventResult struct {
+	Event        string `json:"event"`
+	Code         string `json:"code,omitempty"`
+	Message      string `json:"message,omitempty"`
+	Retryable    bool   `json:"retryable"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+func NewEventAcceptedEvent(userId string, eventId string, eventType string) *EventToPublish {
+	return &EventToPublish{
+		Event:   EVENT_ACCEPTED,
+		EventId: eventId,
+		UserId:  userId,
+		Data: EventResult{
+			Event: eventType,
+		},
+		Ephemeral: true,
+	}
+}
+
+// NewEventFailedEvent builds the EVENT_FAILED frame, retryable tells the client whether sending
+// the same event again may succeed.
+func NewEventFailedEvent(userId string, eventId string, eventType string, code string, retryable bool, err error) *EventToPublish {
+	return &EventToPublish{
+		Event:   EVENT_FAILED,
+		EventId: eventId,
+		UserId:  userId,
+		Data: EventResult{
+			Event:     eventType,
+			Code:      code,
+			Message:   err.Error(),
+			Retryable: retryable,
+		},
+		Ephemeral: true,
+	}
+}
+
+func NewRateLimitedEvent(userId string, eventId string, eventType string, retryAfter time.Duration, err error) *EventToPublish {
+	return &EventToPublish{
+		Event:   RATE_LIMITED,
+		EventId: eventId,
+		UserId:  userId,
+		Data: EventResult{
+			Event:        eventType,
+			Code:         ErrorCodeRateLimited,
+			Message:      err.Error(),
+			Retryable:    true,
+			RetryAfterMs: retryAfter.Milliseconds(),
+		},
+		Ephemeral: true,
+	}
+}
@@ -0,0 +1,153 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const defaultMaxBulkEvents = 500
+
+type HandlerConfig struct {
+	MaxBulkEvents int
+}
+
+// pushHandler lets the other services deliver events to connected users. Events reach every device
+// of the user through the event dispatcher, so offline users find them in their offline inbox.
+type pushHandler struct {
+	eventDispatcher services.EventDispatcher
+	presence        services.Presence
+	topics          services.Topics
+	config          HandlerConfig
+}
+
+func NewHandler(eventDispatcher services.EventDispatcher, presence services.Presence, topics services.Topics, config HandlerConfig) *pushHandler {
+	if config.MaxBulkEvents <= 0 {
+		config.MaxBulkEvents = defaultMaxBulkEvents
+	}
+
+	return &pushHandler{
+		eventDispatcher: eventDispatcher,
+		presence:        presence,
+		topics:          topics,
+		config:          config,
+	}
+}
+
+// PushEvent handles POST /v1/users/:id/events.
+func (h *pushHandler) PushEvent(c *gin.Context) {
+	request := domain.PushEventRequest{}
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.UserId = c.Param("id")
+
+	results, err := h.push(c.Request.Context(), []domain.PushEventRequest{request})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, results[0])
+}
+
+// PushEvents handles POST /v1/events, delivering each event to its own user_id.
+func (h *pushHandler) PushEvents(c *gin.Context) {
+	request := domain.BulkPushEventRequest{}
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(request.Events) > h.config.MaxBulkEvents {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("at most %d events are accepted", h.config.MaxBulkEvents)})
+		return
+	}
+
+	results, err := h.push(c.Request.Context(), request.Events)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"results": results})
+}
+
+// PublishTopicEvent handles POST /v1/topics/:topic/events, delivering the event to every subscriber of the topic.
+func (h *pushHandler) PublishTopicEvent(c *gin.Context) {
+	request := domain.TopicEventRequest{}
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.Event == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event is required"})
+		return
+	}
+
+	if request.EventId == "" {
+		request.EventId = uuid.New().String()
+	}
+
+	err = h.topics.Publish(c.Request.Context(), &domain.EventToPublish{
+		Event:   request.Event,
+		EventId: request.EventId,
+		Topic:   c.Param("topic"),
+		Data:    request.Data,
+	})
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"event_id": request.EventId})
+}
+
+func (h *pushHandler) push(ctx context.Context, requests []domain.PushEventRequest) ([]domain.PushEventResult, error) {
+	userIds := make([]string, 0, len(requests))
+	for i := range requests {
+		err := requests[i].Validate()
+		if err != nil {
+			return nil, fmt.Errorf("events[%d]: %w", i, err)
+		}
+		userIds = append(userIds, requests[i].UserId)
+	}
+
+	online, err := h.presence.GetPresence(ctx, userIds)
+	if err != nil {
+		fmt.Println(util.FailedToLookupUserPod, err)
+		online = map[string][]string{}
+	}
+
+	events := make([]*domain.EventToPublish, 0, len(requests))
+	results := make([]domain.PushEventResult, 0, len(requests))
+	for i := range requests {
+		event := requests[i].ToEventToPublish()
+		if event.EventId == "" {
+			event.EventId = uuid.New().String()
+		}
+		events = append(events, event)
+
+		_, isOnline := online[event.UserId]
+		results = append(results, domain.PushEventResult{
+			UserId: event.UserId,
+			Online: isOnline,
+		})
+	}
+
+	h.eventDispatcher.Dispatch(ctx, events)
+
+	return results, nil
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services/events"
+)
+
+// maxMessageFrameSize is the largest frame of the events carrying a message, the other events use
+// the websocket default.
+const maxMessageFrameSize = 64 * 1024
+
+// newRegistry registers every inbound event type the websocket accepts and the middlewares run around them.
+func newRegistry(dependencies *HandlersDependencies) *events.Registry {
+	registry := events.NewRegistry()
+
+	registry.Use(
+		events.Recover,
+		events.Timeout(dependencies.HandlerTimeout),
+		events.ValidatePayload,
+	)
+
+	registry.Register(domain.ACK, events.EventDefinition{
+		Service: dependencies.Ack,
+		Payload: events.Payload[domain.AckReceived](),
+		Schema:  events.Schema("ack.json"),
+		Options: events.EventOptions{SkipAccepted: true, OrderBy: events.Unordered},
+	})
+	registry.Register(domain.PRESENCE_SUBSCRIBE, events.EventDefinition{
+		Service: dependencies.PresenceSubscribe,
+		Payload: events.Payload[domain.PresenceSubscriptionReceived](),
+		Schema:  events.Schema("presenceSubscription.json"),
+	})
+	registry.Register(domain.PRESENCE_UNSUBSCRIBE, events.EventDefinition{
+		Service: dependencies.PresenceUnsubscribe,
+		Payload: events.Payload[domain.PresenceSubscriptionReceived](),
+		Schema:  events.Schema("presenceSubscription.json"),
+	})
+	registry.Register(domain.SUBSCRIBE, events.EventDefinition{
+		Service: dependencies.Subscribe,
+		Payload: events.Payload[domain.TopicSubscriptionReceived](),
+		Schema:  events.Schema("topicSubscription.json"),
+	})
+	registry.Register(domain.UNSUBSCRIBE, events.EventDefinition{
+		Service: dependencies.Unsubscribe,
+		Payload: events.Payload[domain.TopicSubscriptionReceived](),
+		Schema:  events.Schema("topicSubscription.json"),
+	})
+	registry.Register(domain.TYPING_STARTED, events.EventDefinition{
+		Service: dependencies.TypingIndicators,
+		Payload: events.Payload[domain.TypingReceived](),
+		Schema:  events.Schema("typing.json"),
+		Options: events.EventOptions{SkipAccepted: true, OrderBy: events.Scoped("typing", events.ByChannel)},
+	})
+	registry.Register(domain.TYPING_STOPPED, events.EventDefinition{
+		Service: dependencies.TypingIndicators,
+		Payload: events.Payload[domain.TypingReceived](),
+		Schema:  events.Schema("typing.json"),
+		Options: events.EventOptions{SkipAccepted: true, OrderBy: events.Scoped("typing", events.ByChannel)},
+	})
+	registry.Register(domain.MESSAGE_READ, events.EventDefinition{
+		Service: dependencies.MessageRead,
+		Payload: events.Payload[domain.MessageReadReceived](),
+		Schema:  events.Schema("messageRead.json"),
+		Options: events.EventOptions{OrderBy: events.ByChannel},
+	})
+	registry.Register(domain.MESSAGE_EDIT_REQUESTED, events.EventDefinition{
+		Service: dependencies.MessageEdit,
+		Payload: events.Payload[domain.MessageUpdateReceived](),
+		Schema:  events.Schema("messageEdit.json"),
+		Options: events.EventOptions{OrderBy: events.ByChannel, MaxFrameSize: maxMessageFrameSize},
+	})
+	registry.Register(domain.MESSAGE_DELETE_REQUESTED, events.EventDefinition{
+		Service: dependencies.MessageDelete,
+		Payload: events.Payload[domain.MessageUpdateReceived](),
+		Schema:  events.Schema("messageDelete.json"),
+		Options: events.EventOptions{OrderBy: events.ByChannel},
+	})
+	registry.Register(domain.REACTION_ADDED, events.EventDefinition{
+		Service: dependencies.ReactionAdded,
+		Payload: events.Payload[domain.ReactionReceived](),
+		Schema:  events.Schema("reaction.json"),
+		Options: events.EventOptions{OrderBy: events.ByChannel},
+	})
+	registry.Register(domain.REACTION_REMOVED, events.EventDefinition{
+		Service: dependencies.ReactionRemoved,
+		Payload: events.Payload[domain.ReactionReceived](),
+		Schema:  events.Schema("reaction.json"),
+		Options: events.EventOptions{OrderBy: events.ByChannel},
+	})
+	registry.Register(domain.MESSAGE_SENT, events.EventDefinition{
+		Service: dependencies.MessageSent,
+		Payload: events.Payload[domain.MessageSent](),
+		Schema:  events.Schema("messageSent.json"),
+		Options: events.EventOptions{OrderBy: events.ByChannel, MaxFrameSize: maxMessageFrameSize},
+	})
+	registry.Register(domain.SEARCH_REQUESTED, events.EventDefinition{
+		Service: dependencies.SearchRequested,
+		Payload: events.Payload[domain.SearchRequested](),
+		Schema:  events.Schema("search.json"),
+		Options: events.EventOptions{OrderBy: events.ByUser},
+	})
+	registry.Register(domain.SEARCH_CANCELLED, events.EventDefinition{
+		Service: dependencies.SearchCancelled,
+		Payload: events.Payload[domain.SearchRequested](),
+		Schema:  events.Schema("search.json"),
+		Options: events.EventOptions{OrderBy: events.ByUser},
+	})
+	registry.Register(domain.CHANNEL_ACCEPTED, events.EventDefinition{
+		Service: dependencies.ChannelAccepted,
+		Payload: events.Payload[domain.ChannelResponseReceived](),
+		Schema:  events.Schema("channelResponse.json"),
+		Options: events.EventOptions{OrderBy: events.ByUser},
+	})
+	registry.Register(domain.CHANNEL_REJECTED, events.EventDefinition{
+		Service: dependencies.ChannelRejected,
+		Payload: events.Payload[domain.ChannelResponseReceived](),
+		Schema:  events.Schema("channelResponse.json"),
+		Options: events.EventOptions{OrderBy: events.ByUser},
+	})
+
+	return registry
+}
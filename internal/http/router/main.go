@@ -2,8 +2,10 @@ package router
 
 import (
 	"context"
+	"time"
 
-	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/auth"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/push"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/websocket"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services/events"
@@ -12,31 +14,89 @@ import (
 )
 
 type HandlersDependencies struct {
-	WsConnectionService services.WsConnectionServicer
-	MessageSent         events.Services
-	SearchRequested     events.Services
-	ChannelAccepted     events.Services
-	ChannelRejected     events.Services
+	Authenticator        auth.Authenticator
+	ServiceAuthenticator auth.Authenticator
+	Presence             services.Presence
+	WsConnectionService  services.WsConnectionServicer
+	EventDispatcher      services.EventDispatcher
+	DeliveryTracker      services.DeliveryTracker
+	SessionResumer       services.SessionResumer
+	Lifecycle            *services.Lifecycle
+	PresenceNotifier     services.PresenceNotifier
+	Topics               services.Topics
+	RateLimiter          services.RateLimiter
+	Ack                  events.Services
+	PresenceSubscribe    events.Services
+	PresenceUnsubscribe  events.Services
+	Subscribe            events.Services
+	Unsubscribe          events.Services
+	TypingIndicators     events.Services
+	MessageRead          events.Services
+	MessageEdit          events.Services
+	MessageDelete        events.Services
+	ReactionAdded        events.Services
+	ReactionRemoved      events.Services
+	MessageSent          events.Services
+	SearchRequested      events.Services
+	SearchCancelled      events.Services
+	ChannelAccepted      events.Services
+	ChannelRejected      events.Services
+	WorkerPool           *events.WorkerPool
+	HandlerTimeout       time.Duration
+	WebsocketConfig      websocket.HandlerConfig
+	PushConfig           push.HandlerConfig
 }
 
 func Handlers(ctx context.Context, dependencies *HandlersDependencies) *gin.Engine {
 	gi := gin.New()
 
 	websocketHandler := websocket.NewHandler(
+		dependencies.Authenticator,
 		dependencies.WsConnectionService,
-		map[string]events.Services{
-			"MESSAGE_SENT":          dependencies.MessageSent,
-			domain.SEARCH_REQUESTED: dependencies.SearchRequested,
-			domain.CHANNEL_ACCEPTED: dependencies.ChannelAccepted,
-			domain.CHANNEL_REJECTED: dependencies.ChannelRejected,
-		},
+		dependencies.EventDispatcher,
+		dependencies.DeliveryTracker,
+		dependencies.SessionResumer,
+		dependencies.Lifecycle,
+		dependencies.PresenceNotifier,
+		dependencies.Topics,
+		dependencies.RateLimiter,
+		newRegistry(dependencies),
+		dependencies.WorkerPool,
+		dependencies.WebsocketConfig,
 	)
 
 	gi.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	gi.GET("/ready", func(c *gin.Context) {
+		if dependencies.Lifecycle.IsDraining() {
+			c.JSON(503, gin.H{"status": "draining"})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+
 	gi.GET("/ws", websocketHandler.WebsocketServer)
 
+	pushHandler := push.NewHandler(dependencies.EventDispatcher, dependencies.Presence, dependencies.Topics, dependencies.PushConfig)
+
+	v1 := gi.Group("/v1", authenticateService(dependencies.ServiceAuthenticator))
+	v1.POST("/users/:id/events", pushHandler.PushEvent)
+	v1.POST("/events", pushHandler.PushEvents)
+	v1.POST("/topics/:topic/events", pushHandler.PublishTopicEvent)
+
 	return gi
 }
+
+// authenticateService only lets the internal services holding a token call the HTTP API.
+func authenticateService(authenticator auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(401, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
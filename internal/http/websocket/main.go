@@ -3,12 +3,16 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/auth"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services/events"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -21,118 +25,281 @@ var (
 	}
 )
 
+var errCancelled = errors.New("connection closed before the event was handled")
+
+const (
+	defaultMaxProtocolViolations = 5
+	defaultMaxFrameSize          = 4096
+)
+
+type HandlerConfig struct {
+	// MaxProtocolViolations is the number of malformed, invalid or unknown events tolerated
+	// before the connection is closed with a policy violation.
+	MaxProtocolViolations int
+	// MaxFrameSize is the largest frame in bytes of the events without a MaxFrameSize option.
+	MaxFrameSize int64
+}
+
 type websocketHandler struct {
+	authenticator       auth.Authenticator
 	wsConnectionService services.WsConnectionServicer
-	services            map[string]events.Services
+	eventDispatcher     services.EventDispatcher
+	deliveryTracker     services.DeliveryTracker
+	sessionResumer      services.SessionResumer
+	lifecycle           *services.Lifecycle
+	presenceNotifier    services.PresenceNotifier
+	topics              services.Topics
+	rateLimiter         services.RateLimiter
+	registry            *events.Registry
+	workerPool          *events.WorkerPool
+	config              HandlerConfig
+	// readLimit is the largest frame of any event, longer ones close the connection.
+	readLimit int64
 }
 
 func NewHandler(
+	authenticator auth.Authenticator,
 	wsConnectionService services.WsConnectionServicer,
-	services map[string]events.Services,
+	eventDispatcher services.EventDispatcher,
+	deliveryTracker services.DeliveryTracker,
+	sessionResumer services.SessionResumer,
+	lifecycle *services.Lifecycle,
+	presenceNotifier services.PresenceNotifier,
+	topics services.Topics,
+	rateLimiter services.RateLimiter,
+	registry *events.Registry,
+	workerPool *events.WorkerPool,
+	config HandlerConfig,
 ) *websocketHandler {
+	if config.MaxProtocolViolations <= 0 {
+		config.MaxProtocolViolations = defaultMaxProtocolViolations
+	}
+
+	if config.MaxFrameSize <= 0 {
+		config.MaxFrameSize = defaultMaxFrameSize
+	}
+
 	return &websocketHandler{
+		authenticator:       authenticator,
 		wsConnectionService: wsConnectionService,
-		services:            services,
+		eventDispatcher:     eventDispatcher,
+		deliveryTracker:     deliveryTracker,
+		sessionResumer:      sessionResumer,
+		lifecycle:           lifecycle,
+		presenceNotifier:    presenceNotifier,
+		topics:              topics,
+		rateLimiter:         rateLimiter,
+		registry:            registry,
+		workerPool:          workerPool,
+		config:              config,
+		readLimit:           max(config.MaxFrameSize, registry.MaxFrameSize()),
 	}
 }
 func (h *websocketHandler) WebsocketServer(c *gin.Context) {
-	userId := c.Request.Header.Get("user_id")
-	if userId == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+	if h.lifecycle.IsDraining() {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{})
+		return
+	}
+
+	identity, err := h.authenticator.Authenticate(c.Request)
+	if err != nil {
+		// The reason stays in the logs, it would tell callers how their token or headers were checked.
+		fmt.Println(util.FailedToAuthenticate, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
+	userId := identity.UserId
+
+	var responseHeader http.Header
+	if identity.Subprotocol != "" {
+		responseHeader = http.Header{"Sec-Websocket-Protocol": []string{identity.Subprotocol}}
+	}
 
 	upgrader.CheckOrigin = func(r *http.Request) bool {
 		return true
 	}
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, responseHeader)
 	if err != nil {
 		return
 	}
+	conn.SetReadLimit(h.readLimit)
+	ctx := c.Request.Context()
+	activeConn := h.wsConnectionService.SetConn(ctx, userId, conn)
+	ctx = services.ContextWithConn(ctx, activeConn)
 	defer func() {
-		if err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Connection closed")); err != nil {
-			fmt.Println(err.Error())
-		}
-		conn.Close()
+		h.wsConnectionService.DeleteConn(ctx, userId, activeConn.Id)
+		h.topics.UnsubscribeAll(activeConn)
+		h.presenceNotifier.UserDisconnected(ctx, userId)
 	}()
+	h.presenceNotifier.UserConnected(ctx, userId)
+	go h.wsConnectionService.RefreshConnection(ctx, activeConn)
+	h.resumeSession(ctx, c, activeConn)
+	go h.deliveryTracker.Redeliver(ctx, activeConn)
+	h.eventDispatcher.FlushOfflineInbox(ctx, userId)
 
-	ctx := c.Request.Context()
-	h.wsConnectionService.SetConn(ctx, userId, conn)
-	go h.wsConnectionService.RefreshConnection(ctx, userId)
+	// Cancelled before the cleanup above, it discards the events of the connection still queued in the worker pool.
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	violations := 0
+	protocolViolation := func(errorEvent *domain.EventToPublish) bool {
+		activeConn.Send(errorEvent)
+		violations++
+		if violations >= h.config.MaxProtocolViolations {
+			activeConn.CloseWithReason(websocket.ClosePolicyViolation, "too many protocol violations")
+			return true
+		}
+		return false
+	}
 
 	for {
 		_, msg, err := conn.ReadMessage()
 
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				h.deleteConn(ctx, userId)
+			if h.lifecycle.IsDraining() {
+				// Reading was stopped by the shutdown, which closes the connection once the events
+				// already read are handled, so their answers can still be written.
+				<-activeConn.Done()
 				return
 			}
-
-			h.deleteConn(ctx, userId)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				fmt.Println(util.ConnectionClosedUnexpectedly, err)
+			}
 			return
 		}
 
 		eventReceived := domain.EventReceived{}
 		err = json.Unmarshal(msg, &eventReceived)
 		if err != nil {
-			activeConn := h.wsConnectionService.GetConn(userId)
-			if activeConn != nil {
-				sendEventError(activeConn.Conn, eventReceived.EventId, eventReceived.EventType, err, http.StatusBadRequest)
+			if protocolViolation(domain.NewErrorEvent(userId, eventReceived.EventId, eventReceived.EventType, domain.ErrorCodeInvalidJson, err)) {
+				return
 			}
-			return
+			continue
 		}
 
 		err = eventReceived.Validate()
 		if err != nil {
-			activeConn := h.wsConnectionService.GetConn(userId)
-			if activeConn != nil {
-				sendEventError(activeConn.Conn, eventReceived.EventId, eventReceived.EventType, err, http.StatusBadRequest)
+			if protocolViolation(domain.NewErrorEvent(userId, eventReceived.EventId, eventReceived.EventType, domain.ErrorCodeValidationFailed, err)) {
+				return
 			}
-			return
+			continue
 		}
 
-		service, ok := h.services[eventReceived.EventType]
+		definition, ok := h.registry.Lookup(eventReceived.EventType)
 		if !ok {
-			activeConn := h.wsConnectionService.GetConn(userId)
-			if activeConn != nil {
-				sendEventError(activeConn.Conn, eventReceived.EventId, eventReceived.EventType, fmt.Errorf("event type not found"), http.StatusNotFound)
+			if protocolViolation(domain.NewErrorEvent(userId, eventReceived.EventId, eventReceived.EventType, domain.ErrorCodeUnknownEvent, fmt.Errorf("event type not found"))) {
+				return
 			}
-			return
+			continue
+		}
+
+		maxFrameSize := definition.Options.MaxFrameSize
+		if maxFrameSize <= 0 {
+			maxFrameSize = h.config.MaxFrameSize
+		}
+		if int64(len(msg)) > maxFrameSize {
+			if protocolViolation(domain.NewErrorEvent(userId, eventReceived.EventId, eventReceived.EventType, domain.ErrorCodeFrameTooLarge, fmt.Errorf("frame is larger than %d bytes", maxFrameSize))) {
+				return
+			}
+			continue
+		}
+
+		if definition.Schema != nil {
+			fieldErrors := events.ValidateSchema(definition.Schema, msg)
+			if len(fieldErrors) > 0 {
+				if protocolViolation(domain.NewSchemaErrorEvent(userId, eventReceived.EventId, eventReceived.EventType, fieldErrors)) {
+					return
+				}
+				continue
+			}
+		}
+
+		// Checked before the event is queued, so a client over its limit takes no room in the worker pool.
+		allowed, retryAfter, err := h.rateLimiter.Allow(ctx, userId, eventReceived.EventType)
+		if err != nil {
+			// Events are let through when the limiter fails, so redis being down doesn't stop every user.
+			fmt.Println(util.FailedToRateLimitEvent, err)
+		} else if !allowed {
+			h.answer(activeConn, eventReceived, definition, events.RateLimited(retryAfter))
+			continue
 		}
 
 		eventToPublish := eventReceived.ToEventToPublish(userId)
 		eventBytes, err := json.Marshal(eventToPublish)
 		if err != nil {
-			activeConn := h.wsConnectionService.GetConn(userId)
-			if activeConn != nil {
-				sendEventError(activeConn.Conn, eventReceived.EventId, eventReceived.EventType, err, http.StatusInternalServerError)
-			}
-			return
+			activeConn.Send(domain.NewErrorEvent(userId, eventReceived.EventId, eventReceived.EventType, domain.ErrorCodeInternal, err))
+			continue
 		}
 
-		eventsToPublish := service.Handle(ctx, eventBytes)
-
-		for _, event := range eventsToPublish {
-			activeConn := h.wsConnectionService.GetConn(event.UserId)
-			if activeConn != nil {
-				activeConn.Conn.WriteJSON(event)
-			}
+		event := &events.Event{
+			Type:       eventReceived.EventType,
+			EventId:    eventReceived.EventId,
+			UserId:     userId,
+			Payload:    eventBytes,
+			Definition: definition,
+		}
+		err = h.workerPool.Submit(connCtx, events.Task{
+			Owner: activeConn.Id,
+			Key:   h.registry.Key(event),
+			Run: func(ctx context.Context) {
+				eventsToPublish, err := h.registry.Handle(ctx, event)
+				h.eventDispatcher.Dispatch(ctx, eventsToPublish)
+				h.answer(activeConn, eventReceived, definition, err)
+			},
+			Cancelled: func() {
+				h.answer(activeConn, eventReceived, definition, events.NewHandlerError(domain.ErrorCodeCancelled, true, errCancelled))
+			},
+		})
+		if err != nil {
+			h.answer(activeConn, eventReceived, definition, events.NewHandlerError(domain.ErrorCodeOverloaded, true, err))
 		}
 	}
 }
 
-func (h *websocketHandler) deleteConn(ctx context.Context, userId string) {
-	h.wsConnectionService.DeleteConn(ctx, userId)
+// answer tells the sender whether its event was handled: EVENT_ACCEPTED, or EVENT_FAILED with
+// the error code and whether sending the event again may succeed.
+func (h *websocketHandler) answer(activeConn *services.ActiveConn, eventReceived domain.EventReceived, definition *events.EventDefinition, err error) {
+	if err == nil {
+		if !definition.Options.SkipAccepted {
+			activeConn.Send(domain.NewEventAcceptedEvent(activeConn.UserId, eventReceived.EventId, eventReceived.EventType))
+		}
+		return
+	}
+
+	handlerErr := &events.HandlerError{}
+	if !errors.As(err, &handlerErr) {
+		handlerErr = events.NewHandlerError(domain.ErrorCodeInternal, true, err)
+	}
+	if handlerErr.Code == domain.ErrorCodeRateLimited {
+		activeConn.Send(domain.NewRateLimitedEvent(activeConn.UserId, eventReceived.EventId, eventReceived.EventType, handlerErr.RetryAfter, err))
+		return
+	}
+	if handlerErr.Retryable {
+		fmt.Println(util.FailedToHandleEvent, eventReceived.EventType, err)
+	}
+
+	activeConn.Send(domain.NewEventFailedEvent(activeConn.UserId, eventReceived.EventId, eventReceived.EventType, handlerErr.Code, handlerErr.Retryable, err))
 }
 
-func sendEventError(conn *websocket.Conn, eventId string, eventName string, err error, code int) {
-	conn.WriteJSON(map[string]interface{}{
-		"event_id":   eventId,
-		"event_name": eventName,
-		"content": map[string]interface{}{
-			"error": err.Error(),
-			"code":  code,
-		},
+// resumeSession sends the SESSION_STARTED frame and, when the client reconnects with
+// resume_token and last_seq query parameters, replays the events it missed.
+func (h *websocketHandler) resumeSession(ctx context.Context, c *gin.Context, activeConn *services.ActiveConn) {
+	lastSeq, _ := strconv.ParseInt(c.Query("last_seq"), 10, 64)
+
+	session, missed, err := h.sessionResumer.Resume(ctx, activeConn.UserId, c.Query("resume_token"), lastSeq)
+	if err != nil {
+		fmt.Println(util.FailedToResumeSession, err)
+		return
+	}
+
+	activeConn.Send(&domain.EventToPublish{
+		Event:  domain.SESSION_STARTED,
+		UserId: activeConn.UserId,
+		Data:   session,
 	})
+
+	for _, event := range missed {
+		activeConn.Send(event)
+	}
 }
@@ -2,121 +2,440 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// OverflowPolicyDrop discards the event that did not fit in the send queue.
+	OverflowPolicyDrop = "drop"
+	// OverflowPolicyDisconnect closes the connection of a consumer that can't keep up.
+	OverflowPolicyDisconnect = "disconnect"
+
+	defaultWriteWait             = 10 * time.Second
+	defaultSendQueueSize         = 64
+	defaultUserDisconnectedGrace = 10 * time.Second
+)
+
+var (
+	ErrSendQueueFull    = errors.New("websocket_handler: send queue is full")
+	ErrConnectionClosed = errors.New("websocket_handler: connection is closed")
+)
+
 var mutex sync.RWMutex
 var POD_NAME = os.Getenv("HOSTNAME")
 
+// EventWrittenHook is called, in its own goroutine, after an event is written to a client socket.
+type EventWrittenHook func(activeConn *ActiveConn, event *domain.EventToPublish)
+
+type connContextKey struct{}
+
+// ContextWithConn returns a copy of ctx carrying the connection the events handled with it came from.
+func ContextWithConn(ctx context.Context, activeConn *ActiveConn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, activeConn)
+}
+
+// ConnFromContext returns the connection set by ContextWithConn, or nil.
+func ConnFromContext(ctx context.Context) *ActiveConn {
+	activeConn, _ := ctx.Value(connContextKey{}).(*ActiveConn)
+	return activeConn
+}
+
+// UserDisconnectedHook is called once a user has had no connection left for the grace period.
+type UserDisconnectedHook func(ctx context.Context, userId string)
+
+type outboundFrame struct {
+	messageType int
+	payload     interface{}
+}
+
 type ActiveConn struct {
+	Id      string
+	UserId  string
 	PodName string
 	Conn    *websocket.Conn
 	Time    time.Time
+
+	send           chan outboundFrame
+	done           chan struct{}
+	writerDone     chan struct{}
+	closeOnce      sync.Once
+	closeMessage   []byte
+	flushOnClose   bool
+	overflowPolicy string
+	writeWait      time.Duration
+	onEventWritten EventWrittenHook
+	readStopped    atomic.Bool
+}
+
+// Send enqueues an event to be written by the connection writer goroutine.
+// When the queue is full the configured overflow policy is applied.
+func (a *ActiveConn) Send(event interface{}) error {
+	return a.enqueue(outboundFrame{messageType: websocket.TextMessage, payload: event})
+}
+
+// Ping enqueues a ping control frame.
+func (a *ActiveConn) Ping() error {
+	return a.enqueue(outboundFrame{messageType: websocket.PingMessage})
+}
+
+// Close stops the writer goroutine, sending a normal closure frame to the client.
+func (a *ActiveConn) Close() {
+	a.CloseWithReason(websocket.CloseNormalClosure, "Connection closed")
+}
+
+// CloseWithReason stops the writer goroutine, sending a close frame with the given code and reason
+// after the events already queued. Only the first call has effect.
+func (a *ActiveConn) CloseWithReason(code int, reason string) {
+	a.close(code, reason, true)
+}
+
+func (a *ActiveConn) close(code int, reason string, flush bool) {
+	a.closeOnce.Do(func() {
+		a.closeMessage = websocket.FormatCloseMessage(code, reason)
+		a.flushOnClose = flush
+		close(a.done)
+	})
+}
+
+// StopReading makes the pending and next reads of the connection fail, while events can still be sent.
+func (a *ActiveConn) StopReading() {
+	a.readStopped.Store(true)
+	a.Conn.SetReadDeadline(time.Now())
+}
+
+// Done is closed once the connection stops accepting events.
+func (a *ActiveConn) Done() <-chan struct{} {
+	return a.done
+}
+
+func (a *ActiveConn) enqueue(frame outboundFrame) error {
+	select {
+	case <-a.done:
+		return ErrConnectionClosed
+	default:
+	}
+
+	select {
+	case a.send <- frame:
+		return nil
+	case <-a.done:
+		return ErrConnectionClosed
+	default:
+		if a.overflowPolicy == OverflowPolicyDisconnect {
+			a.close(websocket.ClosePolicyViolation, "send queue overflow", false)
+		}
+		return ErrSendQueueFull
+	}
+}
+
+func (a *ActiveConn) writeLoop() {
+	defer close(a.writerDone)
+	defer a.Conn.Close()
+
+	for {
+		select {
+		case <-a.done:
+			if a.flushOnClose {
+				a.flush()
+			}
+			a.Conn.WriteControl(websocket.CloseMessage, a.closeMessage, time.Now().Add(a.writeWait))
+			return
+		case frame := <-a.send:
+			err := a.write(frame)
+			if err != nil {
+				fmt.Println(util.FailedToSendEventToUser, a.UserId, err)
+				// Close reasons are limited to 123 bytes, the error is only logged.
+				a.close(websocket.CloseInternalServerErr, "write failed", false)
+				return
+			}
+		}
+	}
+}
+
+func (a *ActiveConn) flush() {
+	for {
+		select {
+		case frame := <-a.send:
+			if err := a.write(frame); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (a *ActiveConn) write(frame outboundFrame) error {
+	a.Conn.SetWriteDeadline(time.Now().Add(a.writeWait))
+
+	if frame.messageType == websocket.PingMessage {
+		return a.Conn.WriteMessage(websocket.PingMessage, nil)
+	}
+
+	err := a.Conn.WriteJSON(frame.payload)
+	if err != nil {
+		return err
+	}
+
+	if event, ok := frame.payload.(*domain.EventToPublish); ok && a.onEventWritten != nil {
+		go a.onEventWritten(a, event)
+	}
+	return nil
 }
 
 type WsConnectionServicer interface {
-	SetConn(ctx context.Context, userId string, conn *websocket.Conn)
-	GetConn(userId string) *ActiveConn
-	DeleteConn(ctx context.Context, userId string)
+	SetConn(ctx context.Context, userId string, conn *websocket.Conn) *ActiveConn
+	GetConn(userId string, connId string) *ActiveConn
+	GetConns(userId string) []*ActiveConn
+	DeleteConn(ctx context.Context, userId string, connId string)
 	ConnectionSize() int
-	GetConnStartTime(userId string) time.Time
-	RefreshConnection(ctx context.Context, userId string)
+	GetConnStartTime(userId string, connId string) time.Time
+	RefreshConnection(ctx context.Context, activeConn *ActiveConn)
+	StopReading()
+	CloseAll(ctx context.Context, reason func() string)
+}
+
+// WsConnectionsConfig holds the settings applied to every active connection.
+type WsConnectionsConfig struct {
+	// ReadDeadlineWait is how long a connection may stay silent, pings are sent a little before it.
+	ReadDeadlineWait time.Duration
+	WriteWait        time.Duration
+	SendQueueSize    int
+	OverflowPolicy   string
+	// UserDisconnectedGrace is how long a user must stay without connections before the
+	// user disconnected hook is called, so a quick reconnect doesn't trigger it.
+	UserDisconnectedGrace time.Duration
+}
+
+func (c *WsConnectionsConfig) normalizeConfig() {
+	if c.WriteWait <= 0 {
+		c.WriteWait = defaultWriteWait
+	}
+
+	if c.SendQueueSize <= 0 {
+		c.SendQueueSize = defaultSendQueueSize
+	}
+
+	if c.OverflowPolicy != OverflowPolicyDisconnect {
+		c.OverflowPolicy = OverflowPolicyDrop
+	}
+
+	if c.UserDisconnectedGrace <= 0 {
+		c.UserDisconnectedGrace = defaultUserDisconnectedGrace
+	}
 }
 
 type websocketConnections struct {
-	actives          map[string]*ActiveConn
-	readDeadlineWait time.Duration
-	cache            cache.Cache
+	actives            map[string]map[string]*ActiveConn
+	config             WsConnectionsConfig
+	presence           Presence
+	onEventWritten     EventWrittenHook
+	onUserDisconnected UserDisconnectedHook
+	// closing is set once the pod starts closing its connections, which the users are expected to reopen elsewhere.
+	closing atomic.Bool
 }
 
-func NewWebsocketConnectionsService(readDeadlineWait time.Duration, cache cache.Cache) *websocketConnections {
+func NewWebsocketConnectionsService(config WsConnectionsConfig, presence Presence) *websocketConnections {
+	config.normalizeConfig()
+
 	return &websocketConnections{
-		actives:          make(map[string]*ActiveConn),
-		readDeadlineWait: readDeadlineWait,
-		cache:            cache,
+		actives:  make(map[string]map[string]*ActiveConn),
+		config:   config,
+		presence: presence,
 	}
 }
 
-func (wsConnection *websocketConnections) SetConn(ctx context.Context, userId string, conn *websocket.Conn) {
+// OnUserDisconnected sets the hook called when the user is still offline the grace period after DeleteConn
+// removed their last connection across every pod. It is not called for the connections closed by the shutdown.
+func (wsConnection *websocketConnections) OnUserDisconnected(hook UserDisconnectedHook) {
+	wsConnection.onUserDisconnected = hook
+}
+
+// OnEventWritten sets the hook called for every event written to the connections opened afterwards.
+func (wsConnection *websocketConnections) OnEventWritten(hook EventWrittenHook) {
+	wsConnection.onEventWritten = hook
+}
+
+// SetConn registers a new connection for the user. A user may hold several connections at once,
+// one per device, each one identified by its own connection id.
+func (wsConnection *websocketConnections) SetConn(ctx context.Context, userId string, conn *websocket.Conn) *ActiveConn {
+	activeConn := &ActiveConn{
+		Id:             uuid.New().String(),
+		UserId:         userId,
+		PodName:        os.Getenv("HOSTNAME"),
+		Conn:           conn,
+		Time:           time.Now(),
+		send:           make(chan outboundFrame, wsConnection.config.SendQueueSize),
+		done:           make(chan struct{}),
+		writerDone:     make(chan struct{}),
+		overflowPolicy: wsConnection.config.OverflowPolicy,
+		writeWait:      wsConnection.config.WriteWait,
+		onEventWritten: wsConnection.onEventWritten,
+	}
+	go activeConn.writeLoop()
+
 	mutex.Lock()
-	wsConnection.actives[userId] = &ActiveConn{
-		PodName: os.Getenv("HOSTNAME"),
-		Conn:    conn,
-		Time:    time.Now(),
+	userConns, ok := wsConnection.actives[userId]
+	if !ok {
+		userConns = make(map[string]*ActiveConn)
+		wsConnection.actives[userId] = userConns
 	}
+	userConns[activeConn.Id] = activeConn
 	mutex.Unlock()
-	wsConnection.cache.Set(ctx, userId, POD_NAME)
+	wsConnection.presence.Connect(ctx, userId, activeConn.Id)
+
+	return activeConn
 }
 
-func (wsConnection *websocketConnections) GetConn(userId string) *ActiveConn {
+func (wsConnection *websocketConnections) GetConn(userId string, connId string) *ActiveConn {
 	mutex.RLock()
-	conn := wsConnection.actives[userId]
+	conn := wsConnection.actives[userId][connId]
 	mutex.RUnlock()
 	return conn
 }
 
-func (wsConnection *websocketConnections) DeleteConn(ctx context.Context, userId string) {
-	connToDelete := wsConnection.GetConn(userId)
+// GetConns returns every connection the user holds in this pod.
+func (wsConnection *websocketConnections) GetConns(userId string) []*ActiveConn {
+	mutex.RLock()
+	conns := make([]*ActiveConn, 0, len(wsConnection.actives[userId]))
+	for _, conn := range wsConnection.actives[userId] {
+		conns = append(conns, conn)
+	}
+	mutex.RUnlock()
+	return conns
+}
+
+// DeleteConn closes and unregisters a single connection, leaving the user's other devices untouched.
+func (wsConnection *websocketConnections) DeleteConn(ctx context.Context, userId string, connId string) {
+	connToDelete := wsConnection.GetConn(userId, connId)
 	if connToDelete != nil {
-		connToDelete.Conn.Close()
+		connToDelete.Close()
 	}
 	mutex.Lock()
-	delete(wsConnection.actives, userId)
+	delete(wsConnection.actives[userId], connId)
+	if len(wsConnection.actives[userId]) == 0 {
+		delete(wsConnection.actives, userId)
+	}
 	mutex.Unlock()
-	wsConnection.cache.Delete(ctx, userId)
+	wsConnection.presence.Disconnect(ctx, userId, connId)
+
+	if wsConnection.onUserDisconnected == nil || wsConnection.closing.Load() {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	if online, err := wsConnection.presence.IsOnline(ctx, userId); err != nil || online {
+		return
+	}
+
+	time.AfterFunc(wsConnection.config.UserDisconnectedGrace, func() {
+		if online, err := wsConnection.presence.IsOnline(ctx, userId); err != nil || online {
+			return
+		}
+		wsConnection.onUserDisconnected(ctx, userId)
+	})
 }
 
 func (wsConnection *websocketConnections) ConnectionSize() int {
 	mutex.RLock()
-	amount := len(wsConnection.actives)
+	amount := 0
+	for _, userConns := range wsConnection.actives {
+		amount += len(userConns)
+	}
 	mutex.RUnlock()
 	return amount
 }
 
-func (wsConnection *websocketConnections) GetConnStartTime(userId string) time.Time {
+func (wsConnection *websocketConnections) GetConnStartTime(userId string, connId string) time.Time {
 	mutex.RLock()
-	connTime := wsConnection.actives[userId].Time
+	var connTime time.Time
+	if conn := wsConnection.actives[userId][connId]; conn != nil {
+		connTime = conn.Time
+	}
 	mutex.RUnlock()
 	return connTime
 }
 
-func (wsConnection *websocketConnections) RefreshConnection(ctx context.Context, userId string) {
-	userConn := wsConnection.GetConn(userId)
-	if userConn == nil {
-		return
-	}
-
-	conn := userConn.Conn
+func (wsConnection *websocketConnections) RefreshConnection(ctx context.Context, activeConn *ActiveConn) {
+	conn := activeConn.Conn
+	readDeadlineWait := wsConnection.config.ReadDeadlineWait
 
-	conn.SetReadDeadline(time.Now().Add(wsConnection.readDeadlineWait))
+	conn.SetReadDeadline(time.Now().Add(readDeadlineWait))
 
 	conn.SetPongHandler(func(appData string) error {
-		wsConnection.cache.Set(ctx, userId, POD_NAME)
-		conn.SetReadDeadline(time.Now().Add(wsConnection.readDeadlineWait))
+		wsConnection.presence.Refresh(ctx, activeConn.UserId, activeConn.Id)
+		if !activeConn.readStopped.Load() {
+			conn.SetReadDeadline(time.Now().Add(readDeadlineWait))
+		}
 		return nil
 	})
 
-	ticker := time.NewTicker(wsConnection.readDeadlineWait - 2*time.Second)
+	ticker := time.NewTicker(readDeadlineWait - 2*time.Second)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-activeConn.Done():
+			return
 		case <-ticker.C:
-			mutex.Lock()
-			err := conn.WriteMessage(websocket.PingMessage, nil)
-			mutex.Unlock()
-
-			if err != nil {
-				wsConnection.DeleteConn(ctx, userId)
-				ticker.Stop()
+			err := activeConn.Ping()
+			if errors.Is(err, ErrConnectionClosed) {
+				wsConnection.DeleteConn(ctx, activeConn.UserId, activeConn.Id)
 				return
 			}
 		}
 	}
 }
+
+// StopReading stops reading from every connection of this pod, for the shutdown to wait for the
+// events already read before closing them.
+func (wsConnection *websocketConnections) StopReading() {
+	wsConnection.closing.Store(true)
+	for _, conn := range wsConnection.all() {
+		conn.StopReading()
+	}
+}
+
+// CloseAll sends a going away frame to every connection of this pod and removes them from the cache,
+// then waits, until ctx is done, for the writers to flush the queued events and the close frames.
+// The server doesn't track the hijacked connections, so the process could exit before that otherwise.
+func (wsConnection *websocketConnections) CloseAll(ctx context.Context, reason func() string) {
+	wsConnection.closing.Store(true)
+	conns := wsConnection.all()
+	for _, conn := range conns {
+		conn.CloseWithReason(websocket.CloseGoingAway, reason())
+		wsConnection.DeleteConn(context.WithoutCancel(ctx), conn.UserId, conn.Id)
+	}
+
+	for _, conn := range conns {
+		select {
+		case <-conn.writerDone:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (wsConnection *websocketConnections) all() []*ActiveConn {
+	mutex.RLock()
+	conns := make([]*ActiveConn, 0)
+	for _, userConns := range wsConnection.actives {
+		for _, conn := range userConns {
+			conns = append(conns, conn)
+		}
+	}
+	mutex.RUnlock()
+	return conns
+}
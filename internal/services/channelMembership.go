@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	channelsApi "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/channels"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+)
+
+const (
+	defaultChannelMembersTTL = 30 * time.Second
+	// nonMemberTTL is how long a user found missing from a channel is refused without asking the backend again.
+	nonMemberTTL = 5 * time.Second
+)
+
+var ErrNotChannelMember = errors.New("websocket_handler: user is not a member of the channel")
+
+// ChannelMembership resolves channel members from the channels backend, keeping them in the cache
+// for a short while. Recipients must always come from here, never from the member list sent by clients.
+type ChannelMembership interface {
+	Members(ctx context.Context, channelId string) ([]string, error)
+	// Authorize returns the channel members, or ErrNotChannelMember when userId is not one of them.
+	Authorize(ctx context.Context, channelId string, userId string) ([]string, error)
+	Invalidate(ctx context.Context, channelId string) error
+}
+
+type channelMembership struct {
+	cache       cache.Cache
+	channelsApi channelsApi.ChannelsApi
+	ttl         time.Duration
+}
+
+func NewChannelMembership(cache cache.Cache, channelsApi channelsApi.ChannelsApi, ttl time.Duration) ChannelMembership {
+	if ttl <= 0 {
+		ttl = defaultChannelMembersTTL
+	}
+
+	return &channelMembership{
+		cache:       cache,
+		channelsApi: channelsApi,
+		ttl:         ttl,
+	}
+}
+
+func (m *channelMembership) Members(ctx context.Context, channelId string) ([]string, error) {
+	cached, err := m.cache.Get(ctx, channelMembersKey(channelId))
+	if err == nil && cached != "" {
+		var members []string
+		if json.Unmarshal([]byte(cached), &members) == nil {
+			return members, nil
+		}
+	}
+
+	return m.load(ctx, channelId)
+}
+
+// Authorize reloads the members once when the user is missing from the cached list, so someone
+// who just joined the channel doesn't have to wait for the cache to expire. A user still missing
+// after the reload is refused for nonMemberTTL without reloading, so events sent in a loop by
+// someone outside the channel don't each reach the backend.
+func (m *channelMembership) Authorize(ctx context.Context, channelId string, userId string) ([]string, error) {
+	members, err := m.Members(ctx, channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.Contains(members, userId) {
+		return members, nil
+	}
+
+	nonMember, err := m.cache.Get(ctx, channelNonMemberKey(channelId, userId))
+	if err == nil && nonMember != "" {
+		return nil, ErrNotChannelMember
+	}
+
+	members, err = m.load(ctx, channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(members, userId) {
+		m.cache.SetWithTTL(ctx, channelNonMemberKey(channelId, userId), "1", nonMemberTTL)
+		return nil, ErrNotChannelMember
+	}
+	return members, nil
+}
+
+func (m *channelMembership) Invalidate(ctx context.Context, channelId string) error {
+	return m.cache.Delete(ctx, channelMembersKey(channelId))
+}
+
+func (m *channelMembership) load(ctx context.Context, channelId string) ([]string, error) {
+	channel, err := m.channelsApi.GetMembers(ctx, channelId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0)
+	if channel != nil && channel.Members != nil {
+		members = channel.Members
+	}
+
+	value, err := json.Marshal(members)
+	if err == nil {
+		m.cache.SetWithTTL(ctx, channelMembersKey(channelId), string(value), m.ttl)
+	}
+	return members, nil
+}
+
+func channelMembersKey(channelId string) string {
+	return "channel_members:" + channelId
+}
+
+func channelNonMemberKey(channelId string, userId string) string {
+	return "channel_non_member:" + channelId + ":" + userId
+}
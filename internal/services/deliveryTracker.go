@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAckTimeout      = 10 * time.Second
+	defaultMaxRedeliveries = 5
+	defaultMaxPending      = 500
+	defaultPendingTTL      = 24 * time.Hour
+)
+
+// DeliveryTracker gives at-least-once delivery: every event gets a delivery id and stays
+// pending for the user until the client acknowledges it. A user keeps at most maxPending
+// events, the oldest are dropped, and they expire pendingTTL after the last one was tracked.
+// Pending events belong to the user, not to a device: the ack of any connection clears them, and
+// an event one device received but did not ack in time is redelivered to the others as well.
+type DeliveryTracker interface {
+	Track(ctx context.Context, event *domain.EventToPublish) error
+	Ack(ctx context.Context, userId string, deliveryIds []string) error
+	Redeliver(ctx context.Context, activeConn *ActiveConn)
+}
+
+type pendingDelivery struct {
+	Event    *domain.EventToPublish `json:"event"`
+	SentAt   time.Time              `json:"sent_at"`
+	Attempts int                    `json:"attempts"`
+}
+
+type deliveryTracker struct {
+	cache           cache.Cache
+	ackTimeout      time.Duration
+	maxRedeliveries int
+	maxPending      int64
+	pendingTTL      time.Duration
+}
+
+func NewDeliveryTracker(cache cache.Cache, ackTimeout time.Duration, maxRedeliveries int, maxPending int64, pendingTTL time.Duration) DeliveryTracker {
+	if ackTimeout <= 0 {
+		ackTimeout = defaultAckTimeout
+	}
+
+	if maxRedeliveries <= 0 {
+		maxRedeliveries = defaultMaxRedeliveries
+	}
+
+	if maxPending <= 0 {
+		maxPending = defaultMaxPending
+	}
+
+	if pendingTTL <= 0 {
+		pendingTTL = defaultPendingTTL
+	}
+
+	return &deliveryTracker{
+		cache:           cache,
+		ackTimeout:      ackTimeout,
+		maxRedeliveries: maxRedeliveries,
+		maxPending:      maxPending,
+		pendingTTL:      pendingTTL,
+	}
+}
+
+// Track assigns a delivery id to the event, unless it already has one, and stores it in the user's pending buffer.
+func (t *deliveryTracker) Track(ctx context.Context, event *domain.EventToPublish) error {
+	if event.DeliveryId == "" {
+		event.DeliveryId = uuid.New().String()
+	}
+
+	err := t.save(ctx, event.UserId, &pendingDelivery{
+		Event:  event,
+		SentAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return t.trim(ctx, event.UserId)
+}
+
+// trim drops the oldest pending events of the user beyond maxPending.
+func (t *deliveryTracker) trim(ctx context.Context, userId string) error {
+	count, err := t.cache.HLen(ctx, pendingDeliveriesKey(userId))
+	if err != nil || count <= t.maxPending {
+		return err
+	}
+
+	values, err := t.cache.HGetAll(ctx, pendingDeliveriesKey(userId))
+	if err != nil {
+		return err
+	}
+
+	pendings := make([]pendingDelivery, 0, len(values))
+	for deliveryId, value := range values {
+		pending := pendingDelivery{}
+		err := json.Unmarshal([]byte(value), &pending)
+		if err != nil || pending.Event == nil {
+			t.cache.HDel(ctx, pendingDeliveriesKey(userId), deliveryId)
+			continue
+		}
+		pendings = append(pendings, pending)
+	}
+
+	sort.Slice(pendings, func(i, j int) bool {
+		return pendings[i].SentAt.Before(pendings[j].SentAt)
+	})
+
+	for i := 0; i < len(pendings)-int(t.maxPending); i++ {
+		t.cache.HDel(ctx, pendingDeliveriesKey(userId), pendings[i].Event.DeliveryId)
+	}
+	return nil
+}
+
+func (t *deliveryTracker) Ack(ctx context.Context, userId string, deliveryIds []string) error {
+	for _, deliveryId := range deliveryIds {
+		err := t.cache.HDel(ctx, pendingDeliveriesKey(userId), deliveryId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redeliver writes the pending events that were not acknowledged within the ack timeout to a connection
+// that has just been opened, and keeps doing so until the connection closes. The newer ones are still
+// waiting for the ack of the previous connection, or are resent by the session replay when it resumes.
+func (t *deliveryTracker) Redeliver(ctx context.Context, activeConn *ActiveConn) {
+	t.redeliver(ctx, activeConn, t.ackTimeout)
+
+	ticker := time.NewTicker(t.ackTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-activeConn.Done():
+			return
+		case <-ticker.C:
+			t.redeliver(ctx, activeConn, t.ackTimeout)
+		}
+	}
+}
+
+func (t *deliveryTracker) redeliver(ctx context.Context, activeConn *ActiveConn, olderThan time.Duration) {
+	pendings, err := t.cache.HGetAll(ctx, pendingDeliveriesKey(activeConn.UserId))
+	if err != nil {
+		fmt.Println(util.FailedToLoadPendingDeliveries, err)
+		return
+	}
+
+	for deliveryId, value := range pendings {
+		pending := pendingDelivery{}
+		err := json.Unmarshal([]byte(value), &pending)
+		if err != nil || pending.Event == nil {
+			t.cache.HDel(ctx, pendingDeliveriesKey(activeConn.UserId), deliveryId)
+			continue
+		}
+
+		if time.Since(pending.SentAt) < olderThan {
+			continue
+		}
+
+		if pending.Attempts >= t.maxRedeliveries {
+			t.cache.HDel(ctx, pendingDeliveriesKey(activeConn.UserId), deliveryId)
+			continue
+		}
+
+		// The attempt is recorded first, and only while the event is still pending, so one acked
+		// since it was loaded is neither written back nor sent again.
+		pending.Attempts++
+		pending.SentAt = time.Now()
+		stillPending, err := t.update(ctx, activeConn.UserId, &pending)
+		if err != nil || !stillPending {
+			continue
+		}
+
+		activeConn.Send(pending.Event)
+	}
+}
+
+func (t *deliveryTracker) save(ctx context.Context, userId string, pending *pendingDelivery) error {
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	return t.cache.HSetWithTTL(ctx, pendingDeliveriesKey(userId), pending.Event.DeliveryId, string(value), t.pendingTTL)
+}
+
+func (t *deliveryTracker) update(ctx context.Context, userId string, pending *pendingDelivery) (bool, error) {
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return false, err
+	}
+	return t.cache.HSetIfExistsWithTTL(ctx, pendingDeliveriesKey(userId), pending.Event.DeliveryId, string(value), t.pendingTTL)
+}
+
+func pendingDeliveriesKey(userId string) string {
+	return "pending_deliveries:" + userId
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/pubsubconnector"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
+	"github.com/google/uuid"
+)
+
+// EventDispatcher delivers events to their recipients, wherever they are connected.
+type EventDispatcher interface {
+	Dispatch(ctx context.Context, events []*domain.EventToPublish)
+	FlushOfflineInbox(ctx context.Context, userId string)
+	Listen(ctx context.Context)
+}
+
+type eventDispatcher struct {
+	wsConnectionService WsConnectionServicer
+	presence            Presence
+	deliveryTracker     DeliveryTracker
+	offlineInbox        OfflineInbox
+	sessionResumer      SessionResumer
+	topics              Topics
+	broker              *pubsubconnector.PubSubBroker
+	topicPrefix         string
+}
+
+func NewEventDispatcher(wsConnectionService WsConnectionServicer, presence Presence, deliveryTracker DeliveryTracker, offlineInbox OfflineInbox, sessionResumer SessionResumer, topics Topics, broker *pubsubconnector.PubSubBroker, topicPrefix string) EventDispatcher {
+	return &eventDispatcher{
+		wsConnectionService: wsConnectionService,
+		presence:            presence,
+		deliveryTracker:     deliveryTracker,
+		offlineInbox:        offlineInbox,
+		sessionResumer:      sessionResumer,
+		topics:              topics,
+		broker:              broker,
+		topicPrefix:         topicPrefix,
+	}
+}
+
+// Dispatch writes each event to every device of the recipient connected to this pod,
+// and publishes it to the topic of every other pod holding one of the recipient's connections.
+// Unless ephemeral, events sent to online recipients are numbered and tracked until the client
+// acknowledges them, and the ones addressed to offline recipients are kept in their offline inbox.
+// Events with a topic are published to the topic subscribers instead.
+func (d *eventDispatcher) Dispatch(ctx context.Context, events []*domain.EventToPublish) {
+	for _, event := range events {
+		if event.Topic != "" {
+			err := d.topics.Publish(ctx, event)
+			if err != nil {
+				fmt.Println(util.FailedToPublishMessageToPubSubBroker, err)
+			}
+			continue
+		}
+
+		localConns := d.wsConnectionService.GetConns(event.UserId)
+
+		pods, err := d.presence.GetPods(ctx, event.UserId)
+		if err != nil {
+			fmt.Println(util.FailedToLookupUserPod, err)
+		}
+
+		remotePods := make([]string, 0, len(pods))
+		for _, podName := range pods {
+			if podName != POD_NAME {
+				remotePods = append(remotePods, podName)
+			}
+		}
+
+		if len(localConns) == 0 && len(remotePods) == 0 {
+			fmt.Printf(util.ReceiverNotOnlineInPod, event.UserId, POD_NAME)
+			if event.Ephemeral {
+				continue
+			}
+			err = d.offlineInbox.Store(ctx, event)
+			if err != nil {
+				fmt.Println(util.FailedToStoreOfflineEvent, err)
+			}
+			continue
+		}
+
+		if !event.Ephemeral {
+			d.track(ctx, event)
+		}
+
+		d.deliverLocal(event)
+
+		for _, podName := range remotePods {
+			err = d.broker.Publisher.Publish(ctx, event, &map[string]interface{}{
+				"topic": d.podTopic(podName),
+			})
+			if err != nil {
+				fmt.Println(util.FailedToPublishMessageToPubSubBroker, err)
+				continue
+			}
+			fmt.Printf(util.PublishMessageToPubSubBrokerSuccessfully, event.Event)
+		}
+	}
+}
+
+// FlushOfflineInbox dispatches, in order, the events stored while the user was offline.
+// It must be called after the user's connection is registered.
+func (d *eventDispatcher) FlushOfflineInbox(ctx context.Context, userId string) {
+	events, err := d.offlineInbox.Drain(ctx, userId)
+	if err != nil {
+		fmt.Println(util.FailedToDrainOfflineInbox, err)
+		return
+	}
+
+	d.Dispatch(ctx, events)
+}
+
+// Listen subscribes to this pod's topic and writes every received event to the local sockets.
+// It blocks until the context is cancelled.
+func (d *eventDispatcher) Listen(ctx context.Context) {
+	eventsChan := make(chan []byte)
+	go d.broker.Subscriber.SubscribeAsync(ctx, d.podTopic(POD_NAME), eventsChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-eventsChan:
+			event := domain.EventToPublish{}
+			err := json.Unmarshal(msg, &event)
+			if err != nil {
+				fmt.Println(util.UnableToParseEventResponse, err)
+				continue
+			}
+
+			if !d.deliverLocal(&event) {
+				fmt.Printf(util.ReceiverNotOnlineInPod, event.UserId, POD_NAME)
+			}
+		}
+	}
+}
+
+func (d *eventDispatcher) track(ctx context.Context, event *domain.EventToPublish) {
+	if event.DeliveryId == "" {
+		event.DeliveryId = uuid.New().String()
+	}
+
+	err := d.sessionResumer.Sequence(ctx, event)
+	if err != nil {
+		fmt.Println(util.FailedToSequenceEvent, err)
+	}
+
+	err = d.deliveryTracker.Track(ctx, event)
+	if err != nil {
+		fmt.Println(util.FailedToTrackDelivery, err)
+	}
+}
+
+func (d *eventDispatcher) deliverLocal(event *domain.EventToPublish) bool {
+	activeConns := d.wsConnectionService.GetConns(event.UserId)
+	for _, activeConn := range activeConns {
+		err := activeConn.Send(event)
+		if err != nil {
+			fmt.Println(util.FailedToSendEventToUser, event.UserId, err)
+		}
+	}
+	return len(activeConns) > 0
+}
+
+func (d *eventDispatcher) podTopic(podName string) string {
+	return fmt.Sprintf("%s:%s", d.topicPrefix, podName)
+}
@@ -0,0 +1,33 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+)
+
+type Ack struct {
+	deliveryTracker services.DeliveryTracker
+}
+
+func NewAck(deliveryTracker services.DeliveryTracker) Services {
+	return &Ack{deliveryTracker}
+}
+
+func (s *Ack) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	eventInit := domain.AckReceived{}
+
+	err := json.Unmarshal(eventToParse, &eventInit)
+	if err != nil {
+		return nil, InvalidEvent(err)
+	}
+
+	err = s.deliveryTracker.Ack(ctx, eventInit.UserId, eventInit.Data.DeliveryIds)
+	if err != nil {
+		return nil, Internal(err)
+	}
+
+	return nil, nil
+}
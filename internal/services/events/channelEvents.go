@@ -3,38 +3,44 @@ package events
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
 )
 
+// ChannelEvents records a user's CHANNEL_ACCEPTED or CHANNEL_REJECTED answer to a channel proposal.
 type ChannelEvents struct {
-	event string
+	event       string
+	matchmaking services.Matchmaking
 }
 
-func NewChannelEvents(event string) Services {
-	return &ChannelEvents{event}
+func NewChannelEvents(event string, matchmaking services.Matchmaking) Services {
+	return &ChannelEvents{event, matchmaking}
 }
 
-func (s *ChannelEvents) Handle(ctx context.Context, eventToParse []byte) []*domain.EventToPublish {
-	var events = make([]*domain.EventToPublish, 0)
-	eventInit := domain.ChannelEvents{}
+func (s *ChannelEvents) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	eventInit := domain.ChannelResponseReceived{}
 
 	err := json.Unmarshal(eventToParse, &eventInit)
 	if err != nil {
-		fmt.Println("Error parsing event to publish")
-		return events
+		return nil, InvalidEvent(err)
 	}
 
-	users := eventInit.Users
+	if eventInit.Data.ProposalId == "" {
+		return nil, InvalidEvent(errors.New("proposal_id is required"))
+	}
 
-	for _, userId := range users {
-		event := domain.EventToPublish{
-			UserId: userId,
-			Event:  s.event,
-		}
-		events = append(events, &event)
+	responded, err := s.matchmaking.Respond(ctx, eventInit.Data.ProposalId, eventInit.UserId, s.event == domain.CHANNEL_ACCEPTED, eventInit.EventId)
+	if errors.Is(err, services.ErrNotInProposal) {
+		return nil, Forbidden(err)
+	}
+	if errors.Is(err, services.ErrProposalNotFound) || errors.Is(err, services.ErrProposalClosed) {
+		return nil, InvalidEvent(err)
+	}
+	if err != nil {
+		return nil, Internal(err)
 	}
 
-	return events
+	return responded, nil
 }
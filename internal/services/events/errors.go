@@ -0,0 +1,64 @@
+package events
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+)
+
+// HandlerError is returned by Services when an event can't be handled. Its code and whether the
+// client may retry are sent back to the sender in the EVENT_FAILED frame.
+type HandlerError struct {
+	Code      string
+	Retryable bool
+	// RetryAfter is how long the client should wait before sending the event again, when known.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func NewHandlerError(code string, retryable bool, err error) *HandlerError {
+	return &HandlerError{
+		Code:      code,
+		Retryable: retryable,
+		Err:       err,
+	}
+}
+
+func (e *HandlerError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidEvent is the error of events that can't be handled as they were sent.
+func InvalidEvent(err error) error {
+	return NewHandlerError(domain.ErrorCodeValidationFailed, false, err)
+}
+
+func Forbidden(err error) error {
+	return NewHandlerError(domain.ErrorCodeForbidden, false, err)
+}
+
+// Conflict is the error of events that clash with the current state, e.g. a second search.
+func Conflict(err error) error {
+	return NewHandlerError(domain.ErrorCodeConflict, false, err)
+}
+
+// Unavailable is the error of a failed call to another service, the client may send the event again.
+func Unavailable(err error) error {
+	return NewHandlerError(domain.ErrorCodeDownstreamUnavailable, true, err)
+}
+
+func Internal(err error) error {
+	return NewHandlerError(domain.ErrorCodeInternal, true, err)
+}
+
+// RateLimited is the error of events sent faster than the user's rate limit allows.
+func RateLimited(retryAfter time.Duration) error {
+	handlerErr := NewHandlerError(domain.ErrorCodeRateLimited, true, errors.New("too many events, retry later"))
+	handlerErr.RetryAfter = retryAfter
+	return handlerErr
+}
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	messagesClient "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/messages"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+)
+
+const reactionsDedupeTTL = 24 * time.Hour
+
+// MessageReactions handles REACTION_ADDED and REACTION_REMOVED on messages of the channel. The same user
+// adding the same emoji twice is only persisted once, and every member of the channel receives the updated summary.
+type MessageReactions struct {
+	event             string
+	messagesApi       messagesClient.MessagesApi
+	channelMembership services.ChannelMembership
+	cache             cache.Cache
+}
+
+func NewMessageReactions(event string, messagesApi messagesClient.MessagesApi, channelMembership services.ChannelMembership, cache cache.Cache) Services {
+	return &MessageReactions{event, messagesApi, channelMembership, cache}
+}
+
+func (s *MessageReactions) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	var events = make([]*domain.EventToPublish, 0)
+	eventInit := domain.ReactionReceived{}
+
+	err := json.Unmarshal(eventToParse, &eventInit)
+	if err != nil {
+		return nil, InvalidEvent(err)
+	}
+
+	if eventInit.Data.Channel == nil || eventInit.Data.Channel.ChannelId == "" || eventInit.Data.MessageId == "" || eventInit.Data.Emoji == "" {
+		return nil, InvalidEvent(errors.New("channel.channel_id, message_id and emoji are required"))
+	}
+
+	members, err := s.channelMembership.Authorize(ctx, eventInit.Data.Channel.ChannelId, eventInit.UserId)
+	if errors.Is(err, services.ErrNotChannelMember) {
+		return nil, Forbidden(err)
+	}
+	if err != nil {
+		return nil, Unavailable(err)
+	}
+
+	reactionRequest := domain.ReactionRequest{
+		ChannelId: eventInit.Data.Channel.ChannelId,
+		MessageId: eventInit.Data.MessageId,
+		UserId:    eventInit.UserId,
+		Emoji:     eventInit.Data.Emoji,
+	}
+
+	headers := map[string]string{
+		"X-Request-Id": eventInit.EventId,
+	}
+
+	message, err := s.messagesApi.GetMessage(ctx, reactionRequest.ChannelId, reactionRequest.MessageId, headers)
+	if err != nil {
+		return nil, Unavailable(err)
+	}
+
+	if message.ChannelId != reactionRequest.ChannelId {
+		return nil, Forbidden(errors.New("message does not belong to the channel"))
+	}
+
+	key := reactionsKey(reactionRequest.MessageId, reactionRequest.Emoji)
+
+	var summary *domain.ReactionSummary
+	if s.event == domain.REACTION_REMOVED {
+		// The set may have expired while the reaction still exists, so removals always reach the API.
+		s.cache.SRem(ctx, key, reactionRequest.UserId)
+
+		summary, err = s.messagesApi.RemoveReaction(ctx, reactionRequest, headers)
+		if err != nil {
+			return nil, Unavailable(err)
+		}
+	} else {
+		added, err := s.cache.SAdd(ctx, key, reactionRequest.UserId)
+		if err != nil {
+			return nil, Internal(err)
+		}
+
+		if !added {
+			return nil, nil
+		}
+		s.cache.Expire(ctx, key, reactionsDedupeTTL)
+
+		summary, err = s.messagesApi.AddReaction(ctx, reactionRequest, headers)
+		if err != nil {
+			s.cache.SRem(ctx, key, reactionRequest.UserId)
+			return nil, Unavailable(err)
+		}
+	}
+
+	reactionChanged := domain.ReactionChanged{
+		MessageId: reactionRequest.MessageId,
+		ChannelId: reactionRequest.ChannelId,
+		UserId:    reactionRequest.UserId,
+		Emoji:     reactionRequest.Emoji,
+	}
+	if summary != nil {
+		reactionChanged.Reactions = summary.Reactions
+	}
+
+	for _, memberId := range members {
+		events = append(events, &domain.EventToPublish{
+			Event:   s.event,
+			EventId: eventInit.EventId,
+			UserId:  memberId,
+			Data:    reactionChanged,
+		})
+	}
+
+	return events, nil
+}
+
+func reactionsKey(messageId string, emoji string) string {
+	return "reactions:" + messageId + ":" + emoji
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	messagesClient "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/messages"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
+)
+
+const deliveredReceiptTTL = 24 * time.Hour
+
+// MessageReceipts persists delivery and read receipts and relays them to the message sender.
+// Read receipts come from MESSAGE_READ events, delivery receipts are generated by Delivered
+// once a MESSAGE_RECEIVED is written to the recipient's socket.
+type MessageReceipts struct {
+	messagesApi       messagesClient.MessagesApi
+	channelMembership services.ChannelMembership
+	eventDispatcher   services.EventDispatcher
+	cache             cache.Cache
+}
+
+func NewMessageReceipts(messagesApi messagesClient.MessagesApi, channelMembership services.ChannelMembership, eventDispatcher services.EventDispatcher, cache cache.Cache) *MessageReceipts {
+	return &MessageReceipts{messagesApi, channelMembership, eventDispatcher, cache}
+}
+
+func (s *MessageReceipts) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	eventInit := domain.MessageReadReceived{}
+
+	err := json.Unmarshal(eventToParse, &eventInit)
+	if err != nil {
+		return nil, InvalidEvent(err)
+	}
+
+	if eventInit.Data.ChannelId == "" || (eventInit.Data.MessageId == "" && eventInit.Data.ReadUpTo == nil) {
+		return nil, InvalidEvent(errors.New("channel_id and message_id or read_up_to are required"))
+	}
+
+	_, err = s.channelMembership.Authorize(ctx, eventInit.Data.ChannelId, eventInit.UserId)
+	if errors.Is(err, services.ErrNotChannelMember) {
+		return nil, Forbidden(err)
+	}
+	if err != nil {
+		return nil, Unavailable(err)
+	}
+
+	return s.updateReceipts(ctx, eventInit.EventId, domain.ReceiptRequest{
+		ChannelId: eventInit.Data.ChannelId,
+		UserId:    eventInit.UserId,
+		Status:    domain.RECEIPT_STATUS_READ,
+		MessageId: eventInit.Data.MessageId,
+		ReadUpTo:  eventInit.Data.ReadUpTo,
+	})
+}
+
+// Delivered is the services.EventWrittenHook generating the delivery receipt of a MESSAGE_RECEIVED.
+// The message is written again on redeliveries, session replays and to every device of the user,
+// but only the first write produces a receipt.
+func (s *MessageReceipts) Delivered(activeConn *services.ActiveConn, event *domain.EventToPublish) {
+	if event.Event != domain.MESSAGE_RECEIVED {
+		return
+	}
+
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return
+	}
+
+	message := domain.MessageCreated{}
+	err = json.Unmarshal(data, &message)
+	if err != nil || message.Id == "" {
+		return
+	}
+
+	ctx := context.Background()
+	key := deliveredReceiptKey(message.Id, activeConn.UserId)
+	first, err := s.cache.SetNXWithTTL(ctx, key, "1", deliveredReceiptTTL)
+	if err != nil {
+		fmt.Println(util.FailedToUpdateReceipts, err)
+		return
+	}
+	if !first {
+		return
+	}
+
+	events, err := s.updateReceipts(ctx, event.EventId, domain.ReceiptRequest{
+		ChannelId: message.ChannelId,
+		UserId:    activeConn.UserId,
+		Status:    domain.RECEIPT_STATUS_DELIVERED,
+		MessageId: message.Id,
+	})
+	if err != nil {
+		fmt.Println(util.FailedToUpdateReceipts, err)
+		// A later write of the message may succeed.
+		s.cache.Delete(ctx, key)
+		return
+	}
+	s.eventDispatcher.Dispatch(ctx, events)
+}
+
+func (s *MessageReceipts) updateReceipts(ctx context.Context, eventId string, receiptRequest domain.ReceiptRequest) ([]*domain.EventToPublish, error) {
+	var events = make([]*domain.EventToPublish, 0)
+
+	headers := map[string]string{
+		"X-Request-Id": eventId,
+	}
+
+	receipts, err := s.messagesApi.UpdateReceipts(ctx, receiptRequest, headers)
+	if err != nil {
+		return nil, Unavailable(err)
+	}
+
+	for i := range receipts {
+		if receipts[i].SenderId == "" || receipts[i].SenderId == receiptRequest.UserId {
+			continue
+		}
+		events = append(events, receipts[i].ParseMessageReceiptToEventToPublish(eventId))
+	}
+
+	return events, nil
+}
+
+func deliveredReceiptKey(messageId string, userId string) string {
+	return "receipt_delivered:" + messageId + ":" + userId
+}
@@ -3,32 +3,47 @@ package events
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 
 	messagesClient "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/messages"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
 )
 
+// Services handles one inbound event type. The returned events are dispatched to their recipients,
+// and a non nil error, preferably a *HandlerError, is reported to the sender in an EVENT_FAILED frame.
 type Services interface {
-	Handle(ctx context.Context, eventToParse []byte) []*domain.EventToPublish
+	Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error)
 }
 
 type MessageSent struct {
-	messagesApi messagesClient.MessagesApi
+	messagesApi       messagesClient.MessagesApi
+	channelMembership services.ChannelMembership
 }
 
-func NewMessageSent(messagesClient messagesClient.MessagesApi) Services {
-	return &MessageSent{messagesClient}
+func NewMessageSent(messagesClient messagesClient.MessagesApi, channelMembership services.ChannelMembership) Services {
+	return &MessageSent{messagesClient, channelMembership}
 }
 
-func (s *MessageSent) Handle(ctx context.Context, eventToParse []byte) []*domain.EventToPublish {
+func (s *MessageSent) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
 	var events = make([]*domain.EventToPublish, 0)
 	eventInit := domain.MessageSent{}
 
 	err := json.Unmarshal(eventToParse, &eventInit)
 	if err != nil {
-		fmt.Println("Erro ao fazer unmarshal do evento recebido:", err)
-		return events
+		return nil, InvalidEvent(err)
+	}
+
+	if eventInit.Data.Channel == nil || eventInit.Data.Channel.ChannelId == "" {
+		return nil, InvalidEvent(errors.New("channel.channel_id is required"))
+	}
+
+	members, err := s.channelMembership.Authorize(ctx, eventInit.Data.Channel.ChannelId, eventInit.UserId)
+	if errors.Is(err, services.ErrNotChannelMember) {
+		return nil, Forbidden(err)
+	}
+	if err != nil {
+		return nil, Unavailable(err)
 	}
 
 	messageRequest := domain.MessageRequest{
@@ -44,11 +59,9 @@ func (s *MessageSent) Handle(ctx context.Context, eventToParse []byte) []*domain
 
 	messageCreated, err := s.messagesApi.CreateMessage(ctx, messageRequest, headers)
 	if err != nil {
-		fmt.Println("Erro ao criar mensagem:", err)
-		return events
+		return nil, Unavailable(err)
 	}
 
-	members := eventInit.Data.Channel.Members
 	for _, memberId := range members {
 		if memberId != messageCreated.SenderId {
 			event := messageCreated.ParseMessageCreatedToEventToPublish(memberId, eventInit.EventId)
@@ -65,5 +78,5 @@ func (s *MessageSent) Handle(ctx context.Context, eventToParse []byte) []*domain
 		events = append(events, event)
 	}
 
-	return events
+	return events, nil
 }
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	messagesClient "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/messages"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+)
+
+// MessageUpdates handles MESSAGE_EDIT_REQUESTED and MESSAGE_DELETE_REQUESTED. Only the sender
+// of a message may change it, and the result is sent to every member of the channel.
+type MessageUpdates struct {
+	event             string
+	messagesApi       messagesClient.MessagesApi
+	channelMembership services.ChannelMembership
+}
+
+func NewMessageUpdates(event string, messagesApi messagesClient.MessagesApi, channelMembership services.ChannelMembership) Services {
+	return &MessageUpdates{event, messagesApi, channelMembership}
+}
+
+func (s *MessageUpdates) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	var events = make([]*domain.EventToPublish, 0)
+	eventInit := domain.MessageUpdateReceived{}
+
+	err := json.Unmarshal(eventToParse, &eventInit)
+	if err != nil {
+		return nil, InvalidEvent(err)
+	}
+
+	if eventInit.Data.Channel == nil || eventInit.Data.Channel.ChannelId == "" || eventInit.Data.MessageId == "" {
+		return nil, InvalidEvent(errors.New("channel.channel_id and message_id are required"))
+	}
+
+	channelId := eventInit.Data.Channel.ChannelId
+	messageId := eventInit.Data.MessageId
+
+	members, err := s.channelMembership.Authorize(ctx, channelId, eventInit.UserId)
+	if errors.Is(err, services.ErrNotChannelMember) {
+		return nil, Forbidden(err)
+	}
+	if err != nil {
+		return nil, Unavailable(err)
+	}
+
+	headers := map[string]string{
+		"X-Request-Id": eventInit.EventId,
+	}
+
+	message, err := s.messagesApi.GetMessage(ctx, channelId, messageId, headers)
+	if err != nil {
+		return nil, Unavailable(err)
+	}
+
+	if message.SenderId != eventInit.UserId || message.ChannelId != channelId {
+		return nil, Forbidden(errors.New("only the sender can change a message"))
+	}
+
+	var event string
+	var data interface{}
+
+	if s.event == domain.MESSAGE_DELETE_REQUESTED {
+		err = s.messagesApi.DeleteMessage(ctx, channelId, messageId, headers)
+		if err != nil {
+			return nil, Unavailable(err)
+		}
+		event = domain.MESSAGE_DELETED
+		data = domain.MessageDeleted{MessageId: messageId, ChannelId: channelId}
+	} else {
+		messageUpdated, err := s.messagesApi.UpdateMessage(ctx, domain.MessageUpdateRequest{
+			ChannelId: channelId,
+			MessageId: messageId,
+			Message:   eventInit.Data.Message,
+			Data:      eventInit.Data.Data,
+		}, headers)
+		if err != nil {
+			return nil, Unavailable(err)
+		}
+		event = domain.MESSAGE_EDITED
+		data = messageUpdated
+	}
+
+	for _, memberId := range members {
+		events = append(events, &domain.EventToPublish{
+			Event:   event,
+			EventId: eventInit.EventId,
+			UserId:  memberId,
+			Data:    data,
+		})
+	}
+
+	return events, nil
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+)
+
+// Recover turns a panicking handler into a handler_failed error, so it can't take the connection down.
+func Recover(next Handler) Handler {
+	return func(ctx context.Context, event *Event) (eventsToPublish []*domain.EventToPublish, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				eventsToPublish = nil
+				err = NewHandlerError(domain.ErrorCodeHandlerFailed, false, fmt.Errorf("failed to handle event: %v", r))
+			}
+		}()
+
+		return next(ctx, event)
+	}
+}
+
+// ValidatePayload fails with validation_failed the events that don't decode into their payload type.
+func ValidatePayload(next Handler) Handler {
+	return func(ctx context.Context, event *Event) ([]*domain.EventToPublish, error) {
+		if event.Definition.Payload != nil {
+			err := json.Unmarshal(event.Payload, event.Definition.Payload())
+			if err != nil {
+				return nil, InvalidEvent(err)
+			}
+		}
+
+		return next(ctx, event)
+	}
+}
+
+// Timeout cancels the handler context after the event's timeout option, or defaultTimeout when unset.
+func Timeout(defaultTimeout time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, event *Event) ([]*domain.EventToPublish, error) {
+			timeout := event.Definition.Options.Timeout
+			if timeout <= 0 {
+				timeout = defaultTimeout
+			}
+
+			if timeout <= 0 {
+				return next(ctx, event)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, event)
+		}
+	}
+}
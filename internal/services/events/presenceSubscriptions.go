@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+)
+
+type PresenceSubscriptions struct {
+	event            string
+	presenceNotifier services.PresenceNotifier
+}
+
+func NewPresenceSubscriptions(event string, presenceNotifier services.PresenceNotifier) Services {
+	return &PresenceSubscriptions{event, presenceNotifier}
+}
+
+func (s *PresenceSubscriptions) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	eventInit := domain.PresenceSubscriptionReceived{}
+
+	err := json.Unmarshal(eventToParse, &eventInit)
+	if err != nil {
+		return nil, InvalidEvent(err)
+	}
+
+	if s.event == domain.PRESENCE_UNSUBSCRIBE {
+		err = s.presenceNotifier.Unsubscribe(ctx, eventInit.UserId, eventInit.Data.UserIds)
+		if err != nil {
+			return nil, Internal(err)
+		}
+		return nil, nil
+	}
+
+	events, err := s.presenceNotifier.Subscribe(ctx, eventInit.UserId, eventInit.Data.ChannelId, eventInit.Data.UserIds)
+	if errors.Is(err, services.ErrPresenceForbidden) {
+		return nil, Forbidden(err)
+	}
+	if err != nil {
+		return nil, Internal(err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Event is an inbound event on its way through the middleware chain.
+type Event struct {
+	Type    string
+	EventId string
+	UserId  string
+	// Payload is the event given to Services.Handle, with the user_id of the sender set.
+	Payload    []byte
+	Definition *EventDefinition
+}
+
+// Handler handles an event, the last Handler of the chain calls the registered Services.
+// The events returned are dispatched even when the error is not nil.
+type Handler func(ctx context.Context, event *Event) ([]*domain.EventToPublish, error)
+
+// Middleware wraps every Handler call with a cross-cutting concern, e.g. recovery or validation.
+type Middleware func(next Handler) Handler
+
+type EventOptions struct {
+	// Timeout bounds how long the handler may run, the middleware default applies when zero.
+	Timeout time.Duration
+	// SkipAccepted doesn't answer EVENT_ACCEPTED to the sender, for frequent events like ACK.
+	SkipAccepted bool
+	// OrderBy keys the events that must be handled in the order they were received, ByUser when nil.
+	OrderBy OrderingKey
+	// MaxFrameSize is the largest frame of the event in bytes, the websocket default applies when zero.
+	MaxFrameSize int64
+}
+
+// OrderingKey returns the key of the event in the WorkerPool, events of the same key are handled
+// one at a time. An empty key lets the event run concurrently with any other.
+type OrderingKey func(event *Event) string
+
+// ByUser orders the events of a user, e.g. matchmaking ones.
+func ByUser(event *Event) string {
+	return "user:" + event.UserId
+}
+
+// ByChannel orders the events a user sends to the same channel, events without a channel are ordered ByUser.
+func ByChannel(event *Event) string {
+	payload := struct {
+		Data struct {
+			ChannelId string `json:"channel_id"`
+			Channel   *struct {
+				ChannelId string `json:"channel_id"`
+			} `json:"channel"`
+		} `json:"data"`
+	}{}
+	json.Unmarshal(event.Payload, &payload)
+
+	channelId := payload.Data.ChannelId
+	if payload.Data.Channel != nil && payload.Data.Channel.ChannelId != "" {
+		channelId = payload.Data.Channel.ChannelId
+	}
+	if channelId == "" {
+		return ByUser(event)
+	}
+	return "user:" + event.UserId + ":channel:" + channelId
+}
+
+// Scoped orders the events apart from the ones of the same key in other scopes, e.g. so typing
+// events don't wait for the messages sent to the channel.
+func Scoped(scope string, orderBy OrderingKey) OrderingKey {
+	return func(event *Event) string {
+		key := orderBy(event)
+		if key == "" {
+			return ""
+		}
+		return scope + ":" + key
+	}
+}
+
+// Unordered lets the events run in any order.
+func Unordered(event *Event) string {
+	return ""
+}
+
+type EventDefinition struct {
+	Service Services
+	// Payload returns a new value of the type the event decodes into.
+	Payload func() interface{}
+	// Schema validates the inbound frames of the event before they are handled.
+	Schema  *jsonschema.Schema
+	Options EventOptions
+
+	// handler is the middleware chain ending in Service, built when the event is registered.
+	handler Handler
+}
+
+// Payload is the EventDefinition.Payload of events decoding into T.
+func Payload[T any]() func() interface{} {
+	return func() interface{} {
+		return new(T)
+	}
+}
+
+// Registry knows every inbound event type and runs its Services through the middleware chain.
+type Registry struct {
+	definitions map[string]*EventDefinition
+	middlewares []Middleware
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		definitions: make(map[string]*EventDefinition),
+	}
+}
+
+// Register adds the event type, replacing any previous definition.
+func (r *Registry) Register(eventType string, definition EventDefinition) {
+	definition.handler = r.chain(definition.Service)
+	r.definitions[eventType] = &definition
+}
+
+// Use appends middlewares to the chain, the first one added is the outermost.
+func (r *Registry) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+
+	for _, definition := range r.definitions {
+		definition.handler = r.chain(definition.Service)
+	}
+}
+
+func (r *Registry) chain(service Services) Handler {
+	var handler Handler = func(ctx context.Context, event *Event) ([]*domain.EventToPublish, error) {
+		return service.Handle(ctx, event.Payload)
+	}
+
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	return handler
+}
+
+// Key returns the WorkerPool key of the event, which must be registered.
+func (r *Registry) Key(event *Event) string {
+	if event.Definition == nil {
+		event.Definition = r.definitions[event.Type]
+	}
+
+	if event.Definition.Options.OrderBy == nil {
+		return ByUser(event)
+	}
+	return event.Definition.Options.OrderBy(event)
+}
+
+// MaxFrameSize returns the largest MaxFrameSize option of the registered events.
+func (r *Registry) MaxFrameSize() int64 {
+	var maxFrameSize int64
+	for _, definition := range r.definitions {
+		maxFrameSize = max(maxFrameSize, definition.Options.MaxFrameSize)
+	}
+	return maxFrameSize
+}
+
+func (r *Registry) Lookup(eventType string) (*EventDefinition, bool) {
+	definition, ok := r.definitions[eventType]
+	return definition, ok
+}
+
+// Handle runs the event through the middlewares and its Services. The event must be registered.
+func (r *Registry) Handle(ctx context.Context, event *Event) ([]*domain.EventToPublish, error) {
+	if event.Definition == nil || event.Definition.handler == nil {
+		event.Definition = r.definitions[event.Type]
+	}
+
+	return event.Definition.handler(ctx, event)
+}
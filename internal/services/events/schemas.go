@@ -0,0 +1,81 @@
+package events
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// Schema compiles the embedded JSON Schema file of an event, e.g. "messageSent.json".
+// Schemas are part of the build, so a missing or invalid one panics at startup.
+func Schema(name string) *jsonschema.Schema {
+	file, err := schemaFiles.ReadFile("schemas/" + name)
+	if err != nil {
+		panic(err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+
+	err = compiler.AddResource(name, bytes.NewReader(file))
+	if err != nil {
+		panic(err)
+	}
+	return compiler.MustCompile(name)
+}
+
+// ValidateSchema validates the inbound frame against the schema of its event, returning
+// an error for every field that doesn't match it.
+func ValidateSchema(schema *jsonschema.Schema, frame []byte) []domain.FieldError {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(frame))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	if err != nil {
+		return []domain.FieldError{{Message: err.Error()}}
+	}
+
+	err = schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+
+	validationErr := &jsonschema.ValidationError{}
+	if !errors.As(err, &validationErr) {
+		return []domain.FieldError{{Message: err.Error()}}
+	}
+
+	return fieldErrors(validationErr, make([]domain.FieldError, 0))
+}
+
+// fieldErrors collects the causes without causes of their own, the others only tell which
+// part of the schema failed.
+func fieldErrors(validationErr *jsonschema.ValidationError, errs []domain.FieldError) []domain.FieldError {
+	if len(validationErr.Causes) == 0 {
+		return append(errs, domain.FieldError{
+			Field:   fieldName(validationErr.InstanceLocation),
+			Message: validationErr.Message,
+		})
+	}
+
+	for _, cause := range validationErr.Causes {
+		errs = fieldErrors(cause, errs)
+	}
+	return errs
+}
+
+// fieldName turns the JSON pointer of a field into its dotted name, e.g. "/data/channel" into "data.channel".
+func fieldName(instanceLocation string) string {
+	field := strings.ReplaceAll(strings.TrimPrefix(instanceLocation, "/"), "/", ".")
+	field = strings.ReplaceAll(field, "~1", "/")
+	return strings.ReplaceAll(field, "~0", "~")
+}
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+)
+
+type SearchCancelled struct {
+	matchmaking services.Matchmaking
+}
+
+func NewSearchCancelled(matchmaking services.Matchmaking) Services {
+	return &SearchCancelled{matchmaking}
+}
+
+func (s *SearchCancelled) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	eventInit := domain.SearchRequested{}
+
+	err := json.Unmarshal(eventToParse, &eventInit)
+	if err != nil {
+		return nil, InvalidEvent(err)
+	}
+
+	cancelled, err := s.matchmaking.Cancel(ctx, eventInit.UserId, eventInit.EventId)
+	if errors.Is(err, services.ErrNoActiveSearch) {
+		return nil, Conflict(err)
+	}
+	if err != nil {
+		return nil, Internal(err)
+	}
+
+	return cancelled, nil
+}
@@ -3,50 +3,35 @@ package events
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 
-	sorterApi "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/sorter"
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
 )
 
 type SearchRequested struct {
-	sorterApi sorterApi.SorterApi
+	matchmaking services.Matchmaking
 }
 
-func NewSearchRequested(sorterApi sorterApi.SorterApi) Services {
-	return &SearchRequested{sorterApi}
+func NewSearchRequested(matchmaking services.Matchmaking) Services {
+	return &SearchRequested{matchmaking}
 }
 
-func (s *SearchRequested) Handle(ctx context.Context, eventToParse []byte) []*domain.EventToPublish {
-	var events = make([]*domain.EventToPublish, 0)
+func (s *SearchRequested) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
 	eventInit := domain.SearchRequested{}
 
 	err := json.Unmarshal(eventToParse, &eventInit)
 	if err != nil {
-		fmt.Println("Error parsing event to publish")
-		return events
+		return nil, InvalidEvent(err)
 	}
 
-	sortResponse, err := s.sorterApi.Sort(ctx, eventInit.UserId)
-	if err != nil {
-		fmt.Println("Error sorting, ", err)
-		return events
+	found, err := s.matchmaking.Search(ctx, eventInit.UserId, eventInit.EventId)
+	if errors.Is(err, services.ErrSearchInProgress) {
+		return nil, Conflict(err)
 	}
-
-	fmt.Println(sortResponse)
-
-	users := sortResponse.Users
-
-	for _, user := range users {
-		event := domain.EventToPublish{
-			UserId: user.Id,
-			Event:  domain.CHANNEL_FOUND,
-			Data:   sortResponse,
-		}
-		events = append(events, &event)
+	if err != nil {
+		return nil, Unavailable(err)
 	}
 
-	fmt.Println(events)
-
-	return events
+	return found, nil
 }
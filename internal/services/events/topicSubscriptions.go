@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+)
+
+// TopicSubscriptions handles SUBSCRIBE and UNSUBSCRIBE. Subscriptions belong to the connection the
+// event came from, so they are confirmed to that connection only, and refused topics are reported
+// to the sender as forbidden.
+type TopicSubscriptions struct {
+	event  string
+	topics services.Topics
+}
+
+func NewTopicSubscriptions(event string, topics services.Topics) Services {
+	return &TopicSubscriptions{event, topics}
+}
+
+func (s *TopicSubscriptions) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	eventInit := domain.TopicSubscriptionReceived{}
+
+	err := json.Unmarshal(eventToParse, &eventInit)
+	if err != nil {
+		return nil, InvalidEvent(err)
+	}
+
+	if len(eventInit.Data.Topics) == 0 {
+		return nil, InvalidEvent(errors.New("topics is required"))
+	}
+
+	activeConn := services.ConnFromContext(ctx)
+	if activeConn == nil {
+		return nil, Internal(errors.New("event has no connection"))
+	}
+
+	if s.event == domain.UNSUBSCRIBE {
+		s.topics.Unsubscribe(activeConn, eventInit.Data.Topics)
+		activeConn.Send(topicSubscriptionEvent(domain.UNSUBSCRIBED, eventInit, eventInit.Data.Topics))
+		return nil, nil
+	}
+
+	// Some topics may be subscribed even when others are refused.
+	subscribed, err := s.topics.Subscribe(ctx, activeConn, eventInit.Data.Topics)
+	if len(subscribed) > 0 {
+		activeConn.Send(topicSubscriptionEvent(domain.SUBSCRIBED, eventInit, subscribed))
+	}
+
+	if errors.Is(err, services.ErrTopicForbidden) {
+		return nil, Forbidden(err)
+	}
+	if err != nil {
+		return nil, Unavailable(err)
+	}
+	return nil, nil
+}
+
+func topicSubscriptionEvent(event string, eventInit domain.TopicSubscriptionReceived, topics []string) *domain.EventToPublish {
+	return &domain.EventToPublish{
+		Event:   event,
+		EventId: eventInit.EventId,
+		UserId:  eventInit.UserId,
+		Data: domain.TopicSubscription{
+			Topics: topics,
+		},
+		Ephemeral: true,
+	}
+}
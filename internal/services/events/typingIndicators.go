@@ -0,0 +1,165 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+)
+
+const (
+	defaultTypingTimeout  = 5 * time.Second
+	defaultTypingThrottle = 2 * time.Second
+)
+
+type typingState struct {
+	members       []string
+	lastForwarded time.Time
+	expiresAt     time.Time
+	expiration    *time.Timer
+}
+
+// TypingIndicators relays TYPING_STARTED and TYPING_STOPPED to the other channel members as ephemeral
+// events. Repeated STARTED events are throttled, and a STOPPED is sent on the user's behalf when no
+// STARTED refreshes the indicator within the timeout.
+type TypingIndicators struct {
+	eventDispatcher   services.EventDispatcher
+	channelMembership services.ChannelMembership
+	timeout           time.Duration
+	throttle          time.Duration
+
+	mu     sync.Mutex
+	typing map[string]*typingState
+}
+
+func NewTypingIndicators(eventDispatcher services.EventDispatcher, channelMembership services.ChannelMembership, timeout time.Duration, throttle time.Duration) Services {
+	if timeout <= 0 {
+		timeout = defaultTypingTimeout
+	}
+
+	if throttle <= 0 {
+		throttle = defaultTypingThrottle
+	}
+
+	return &TypingIndicators{
+		eventDispatcher:   eventDispatcher,
+		channelMembership: channelMembership,
+		timeout:           timeout,
+		throttle:          throttle,
+		typing:            make(map[string]*typingState),
+	}
+}
+
+func (s *TypingIndicators) Handle(ctx context.Context, eventToParse []byte) ([]*domain.EventToPublish, error) {
+	eventInit := domain.TypingReceived{}
+
+	err := json.Unmarshal(eventToParse, &eventInit)
+	if err != nil {
+		return nil, InvalidEvent(err)
+	}
+
+	if eventInit.Data.Channel == nil || eventInit.Data.Channel.ChannelId == "" {
+		return nil, InvalidEvent(errors.New("channel.channel_id is required"))
+	}
+
+	userId := eventInit.UserId
+	channelId := eventInit.Data.Channel.ChannelId
+	key := userId + ":" + channelId
+
+	// A throttled STARTED only refreshes the indicator, without going through the membership again.
+	if eventInit.Event == domain.TYPING_STARTED && s.refresh(key) {
+		return nil, nil
+	}
+
+	members, err := s.channelMembership.Authorize(ctx, channelId, userId)
+	if errors.Is(err, services.ErrNotChannelMember) {
+		return nil, Forbidden(err)
+	}
+	if err != nil {
+		return nil, Unavailable(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, typing := s.typing[key]
+
+	if eventInit.Event == domain.TYPING_STOPPED {
+		if !typing {
+			return nil, nil
+		}
+		state.expiration.Stop()
+		delete(s.typing, key)
+		return typingEvents(domain.TYPING_STOPPED, userId, channelId, state.members), nil
+	}
+
+	if !typing {
+		state = &typingState{}
+		s.typing[key] = state
+		state.expiration = time.AfterFunc(s.timeout, func() {
+			s.expire(context.WithoutCancel(ctx), key, userId, channelId, state)
+		})
+	} else {
+		state.expiration.Reset(s.timeout)
+	}
+	state.expiresAt = time.Now().Add(s.timeout)
+	state.members = members
+
+	if time.Since(state.lastForwarded) < s.throttle {
+		return nil, nil
+	}
+	state.lastForwarded = time.Now()
+
+	return typingEvents(domain.TYPING_STARTED, userId, channelId, state.members), nil
+}
+
+// refresh extends the indicator of a user already typing, reporting false when it is not or when
+// the throttle has passed and the event must be forwarded.
+func (s *TypingIndicators) refresh(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, typing := s.typing[key]
+	if !typing || time.Since(state.lastForwarded) >= s.throttle {
+		return false
+	}
+
+	state.expiration.Reset(s.timeout)
+	state.expiresAt = time.Now().Add(s.timeout)
+	return true
+}
+
+func (s *TypingIndicators) expire(ctx context.Context, key string, userId string, channelId string, state *typingState) {
+	s.mu.Lock()
+	if s.typing[key] != state || time.Now().Before(state.expiresAt) {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.typing, key)
+	s.mu.Unlock()
+
+	s.eventDispatcher.Dispatch(ctx, typingEvents(domain.TYPING_STOPPED, userId, channelId, state.members))
+}
+
+func typingEvents(event string, userId string, channelId string, members []string) []*domain.EventToPublish {
+	events := make([]*domain.EventToPublish, 0, len(members))
+	for _, memberId := range members {
+		if memberId == userId {
+			continue
+		}
+		events = append(events, &domain.EventToPublish{
+			Event:  event,
+			UserId: memberId,
+			Data: domain.TypingChanged{
+				ChannelId: channelId,
+				UserId:    userId,
+			},
+			Ephemeral: true,
+		})
+	}
+	return events
+}
@@ -0,0 +1,193 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/services"
+)
+
+var (
+	ErrWorkerPoolFull = errors.New("websocket_handler: too many events waiting to be handled")
+	ErrTooManyEvents  = errors.New("websocket_handler: too many events of the connection waiting to be handled")
+)
+
+type WorkerPoolConfig struct {
+	Workers int
+	// QueueSize is the number of tasks queued or running across every connection.
+	QueueSize int
+	// MaxTasksPerOwner is the number of tasks a single connection may have queued or running,
+	// so one client can't take the whole queue.
+	MaxTasksPerOwner int
+}
+
+// WorkerPool runs the inbound events of every connection on a bounded number of workers.
+// Tasks submitted with the same key run one at a time in the order they were submitted,
+// tasks with different keys, or without a key, run concurrently.
+type WorkerPool struct {
+	lifecycle *services.Lifecycle
+	tasks     chan *task
+	config    WorkerPoolConfig
+
+	mu     sync.Mutex
+	queued int
+	owners map[string]int
+	// waiting holds, for every key with a task running or ready to run, the tasks submitted after it.
+	waiting map[string][]*task
+}
+
+// Task is a unit of work of the WorkerPool.
+type Task struct {
+	// Owner is the connection the task comes from.
+	Owner string
+	Key   string
+	Run   func(ctx context.Context)
+	// Cancelled is called instead of Run when the task is dropped.
+	Cancelled func()
+}
+
+type task struct {
+	Task
+	ctx context.Context
+}
+
+// NewWorkerPool starts the workers, which stop once the context is done. Queued and running tasks
+// are in-flight events of the lifecycle.
+func NewWorkerPool(ctx context.Context, lifecycle *services.Lifecycle, config WorkerPoolConfig) *WorkerPool {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = config.Workers
+	}
+	if config.MaxTasksPerOwner <= 0 || config.MaxTasksPerOwner > config.QueueSize {
+		config.MaxTasksPerOwner = config.QueueSize
+	}
+
+	p := &WorkerPool{
+		lifecycle: lifecycle,
+		tasks:     make(chan *task, config.QueueSize),
+		config:    config,
+		owners:    make(map[string]int),
+		waiting:   make(map[string][]*task),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		go p.work(ctx)
+	}
+	return p
+}
+
+// Submit queues the task, returning ErrTooManyEvents when its owner already has MaxTasksPerOwner
+// tasks queued or running, and ErrWorkerPoolFull when the whole queue is.
+// A task whose context is done by the time a worker picks it up is cancelled instead of run,
+// so cancelling the context of a connection discards its queued events. Tasks already
+// running get a context that is not cancelled with it.
+func (p *WorkerPool) Submit(ctx context.Context, submitted Task) error {
+	t := &task{submitted, ctx}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.owners[t.Owner] >= p.config.MaxTasksPerOwner {
+		return ErrTooManyEvents
+	}
+	if p.queued >= p.config.QueueSize {
+		return ErrWorkerPoolFull
+	}
+	p.queued++
+	p.owners[t.Owner]++
+	p.lifecycle.Begin()
+
+	if t.Key != "" {
+		if waiting, ok := p.waiting[t.Key]; ok {
+			p.waiting[t.Key] = append(waiting, t)
+			return nil
+		}
+		p.waiting[t.Key] = nil
+	}
+
+	// Never blocks, the channel has room for every queued task.
+	p.tasks <- t
+	return nil
+}
+
+func (p *WorkerPool) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-p.tasks:
+			if t.ctx.Err() == nil {
+				t.Run(context.WithoutCancel(t.ctx))
+			} else if t.Cancelled != nil {
+				t.Cancelled()
+			}
+			p.done(t)
+		}
+	}
+}
+
+// Cancel drops every queued task, calling its Cancelled, for the shutdown to answer the events
+// it can't wait for before closing the connections. Running tasks are left to finish.
+func (p *WorkerPool) Cancel() {
+	p.mu.Lock()
+	dropped := make([]*task, 0)
+	for len(p.tasks) > 0 {
+		// A ready task has no task of its key running, the ones waiting behind it are dropped too.
+		t := <-p.tasks
+		dropped = append(dropped, t)
+		if t.Key != "" {
+			dropped = append(dropped, p.waiting[t.Key]...)
+			delete(p.waiting, t.Key)
+		}
+	}
+	for key, waiting := range p.waiting {
+		dropped = append(dropped, waiting...)
+		p.waiting[key] = nil
+	}
+	p.mu.Unlock()
+
+	for _, t := range dropped {
+		if t.Cancelled != nil {
+			t.Cancelled()
+		}
+		p.release(t)
+	}
+}
+
+// done releases the slot of the task and makes the next task of its key ready to run.
+func (p *WorkerPool) done(t *task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.releaseLocked(t)
+	if t.Key == "" {
+		return
+	}
+
+	waiting := p.waiting[t.Key]
+	if len(waiting) == 0 {
+		delete(p.waiting, t.Key)
+		return
+	}
+
+	p.waiting[t.Key] = waiting[1:]
+	p.tasks <- waiting[0]
+}
+
+func (p *WorkerPool) release(t *task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.releaseLocked(t)
+}
+
+func (p *WorkerPool) releaseLocked(t *task) {
+	p.queued--
+	p.owners[t.Owner]--
+	if p.owners[t.Owner] == 0 {
+		delete(p.owners, t.Owner)
+	}
+	p.lifecycle.End()
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Lifecycle tracks whether the pod is draining and how many events are being handled,
+// so a shutdown can stop taking new work and wait for the ongoing one.
+type Lifecycle struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight int
+	idle     chan struct{}
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// StartDraining makes the pod unready and refuses new websocket upgrades.
+func (l *Lifecycle) StartDraining() {
+	l.mu.Lock()
+	l.draining = true
+	l.mu.Unlock()
+}
+
+func (l *Lifecycle) IsDraining() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.draining
+}
+
+// Begin registers an in-flight event, it must be paired with a call to End.
+func (l *Lifecycle) Begin() {
+	l.mu.Lock()
+	l.inFlight++
+	l.mu.Unlock()
+}
+
+func (l *Lifecycle) End() {
+	l.mu.Lock()
+	l.inFlight--
+	if l.inFlight == 0 && l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+	l.mu.Unlock()
+}
+
+// Wait blocks until there are no in-flight events or the context is done.
+func (l *Lifecycle) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.inFlight == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	if l.idle == nil {
+		l.idle = make(chan struct{})
+	}
+	idle := l.idle
+	l.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReconnectHint builds the close reason sent to clients on shutdown. The delay is jittered
+// so the clients of a draining pod don't all reconnect at the same time.
+func ReconnectHint(maxJitter time.Duration) string {
+	reconnectAfter := time.Duration(0)
+	if maxJitter > 0 {
+		reconnectAfter = time.Duration(rand.Int63n(int64(maxJitter)))
+	}
+
+	hint, _ := json.Marshal(map[string]int64{
+		"reconnect_after_ms": reconnectAfter.Milliseconds(),
+	})
+	return string(hint)
+}
@@ -0,0 +1,552 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	channelsApi "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/channels"
+	sorterApi "github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/clients/sorter"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultProposalTimeout     = 60 * time.Second
+	defaultSearchTimeout       = 2 * time.Minute
+	defaultSearchRetryInterval = 5 * time.Second
+	proposalRetention          = time.Minute
+
+	proposalField    = "proposal"
+	responseAccepted = "accepted"
+	responseRejected = "rejected"
+)
+
+var (
+	ErrProposalNotFound = errors.New("websocket_handler: channel proposal not found")
+	ErrProposalClosed   = errors.New("websocket_handler: channel proposal is already closed")
+	ErrNotInProposal    = errors.New("websocket_handler: user is not part of the channel proposal")
+	ErrSearchInProgress = errors.New("websocket_handler: user already has a search in progress")
+	ErrNoActiveSearch   = errors.New("websocket_handler: user has no search in progress")
+)
+
+// Matchmaking turns the groups found by the sorter into channel proposals. Each proposal waits
+// for every user to accept it before the channel is created, and is cancelled on the first reject
+// or when its deadline passes.
+//
+// A user holds at most one search at a time. The search keeps asking the sorter until a group is
+// found or the search times out, and ends once the proposal it produced is closed.
+type Matchmaking interface {
+	Search(ctx context.Context, userId string, eventId string) ([]*domain.EventToPublish, error)
+	Cancel(ctx context.Context, userId string, eventId string) ([]*domain.EventToPublish, error)
+	// Leave takes a user who went away out of their search, rejecting any proposal still waiting for them.
+	Leave(ctx context.Context, userId string)
+	Respond(ctx context.Context, proposalId string, userId string, accepted bool, eventId string) ([]*domain.EventToPublish, error)
+}
+
+type MatchmakingConfig struct {
+	// Timeout is how long a proposal waits for every user to answer.
+	Timeout             time.Duration
+	SearchTimeout       time.Duration
+	SearchRetryInterval time.Duration
+	// Requeue starts a new search for the users who had accepted a cancelled proposal.
+	Requeue bool
+}
+
+type matchmaking struct {
+	cache           cache.Cache
+	sorterApi       sorterApi.SorterApi
+	channelsApi     channelsApi.ChannelsApi
+	eventDispatcher EventDispatcher
+	config          MatchmakingConfig
+}
+
+func NewMatchmaking(cache cache.Cache, sorterApi sorterApi.SorterApi, channelsApi channelsApi.ChannelsApi, eventDispatcher EventDispatcher, config MatchmakingConfig) Matchmaking {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultProposalTimeout
+	}
+
+	if config.SearchTimeout <= 0 {
+		config.SearchTimeout = defaultSearchTimeout
+	}
+
+	if config.SearchRetryInterval <= 0 {
+		config.SearchRetryInterval = defaultSearchRetryInterval
+	}
+
+	return &matchmaking{
+		cache:           cache,
+		sorterApi:       sorterApi,
+		channelsApi:     channelsApi,
+		eventDispatcher: eventDispatcher,
+		config:          config,
+	}
+}
+
+// Search starts the user's search and asks the sorter for a group right away. When none is found
+// the sorter is asked again in the background, and the group is proposed as soon as it shows up.
+func (m *matchmaking) Search(ctx context.Context, userId string, eventId string) ([]*domain.EventToPublish, error) {
+	if m.pendingProposal(ctx, userId) != "" {
+		return nil, ErrSearchInProgress
+	}
+
+	sessionId := uuid.New().String()
+	started, err := m.cache.SetNXWithTTL(ctx, searchSessionKey(userId), sessionId, m.config.SearchTimeout+m.config.Timeout+proposalRetention)
+	if err != nil {
+		return nil, err
+	}
+
+	if !started {
+		return nil, ErrSearchInProgress
+	}
+
+	events, err := m.propose(ctx, userId, eventId)
+	if err != nil {
+		m.cache.Delete(ctx, searchSessionKey(userId))
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		go m.poll(context.WithoutCancel(ctx), userId, sessionId, eventId)
+	}
+	return events, nil
+}
+
+// Cancel stops the user's search, rejecting the proposal it is waiting on, if any.
+func (m *matchmaking) Cancel(ctx context.Context, userId string, eventId string) ([]*domain.EventToPublish, error) {
+	rejected, err := m.stopSearch(ctx, userId, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*domain.EventToPublish{{
+		Event:   domain.SEARCH_CANCELLED,
+		EventId: eventId,
+		UserId:  userId,
+	}}
+	return append(events, rejected...), nil
+}
+
+func (m *matchmaking) Leave(ctx context.Context, userId string) {
+	rejected, err := m.stopSearch(ctx, userId, "")
+	if err != nil {
+		return
+	}
+
+	m.eventDispatcher.Dispatch(ctx, rejected)
+}
+
+func (m *matchmaking) stopSearch(ctx context.Context, userId string, eventId string) ([]*domain.EventToPublish, error) {
+	sessionId, err := m.cache.Get(ctx, searchSessionKey(userId))
+	if err != nil {
+		return nil, err
+	}
+
+	proposalId := m.pendingProposal(ctx, userId)
+	if sessionId == "" && proposalId == "" {
+		return nil, ErrNoActiveSearch
+	}
+
+	m.cache.Delete(ctx, searchSessionKey(userId))
+
+	if proposalId == "" {
+		return nil, nil
+	}
+
+	rejected, err := m.Respond(ctx, proposalId, userId, false, eventId)
+	if err != nil {
+		fmt.Println(util.FailedToRejectProposal, err)
+		return nil, nil
+	}
+	return rejected, nil
+}
+
+// poll keeps asking the sorter for a group until one is found, the search ends or it times out.
+func (m *matchmaking) poll(ctx context.Context, userId string, sessionId string, eventId string) {
+	ticker := time.NewTicker(m.config.SearchRetryInterval)
+	defer ticker.Stop()
+
+	deadline := time.NewTimer(m.config.SearchTimeout)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-deadline.C:
+			// A proposal found meanwhile ends the search itself once it is closed.
+			if m.pendingProposal(ctx, userId) != "" {
+				return
+			}
+
+			if m.endSearch(ctx, userId, sessionId) {
+				m.eventDispatcher.Dispatch(ctx, []*domain.EventToPublish{{
+					Event:   domain.SEARCH_TIMED_OUT,
+					EventId: eventId,
+					UserId:  userId,
+				}})
+			}
+			return
+		case <-ticker.C:
+			current, err := m.cache.Get(ctx, searchSessionKey(userId))
+			if err != nil {
+				continue
+			}
+
+			if current != sessionId {
+				return
+			}
+
+			// Someone else's search may have placed the user in a proposal already.
+			if m.pendingProposal(ctx, userId) != "" {
+				continue
+			}
+
+			events, err := m.propose(ctx, userId, eventId)
+			if err != nil {
+				fmt.Println(util.FailedToSearchChannel, err)
+				continue
+			}
+
+			if len(events) > 0 {
+				m.eventDispatcher.Dispatch(ctx, events)
+				return
+			}
+		}
+	}
+}
+
+// endSearch removes the search, reporting false when it was already replaced or removed.
+func (m *matchmaking) endSearch(ctx context.Context, userId string, sessionId string) bool {
+	current, err := m.cache.Get(ctx, searchSessionKey(userId))
+	if err != nil || current != sessionId {
+		return false
+	}
+
+	return m.cache.Delete(ctx, searchSessionKey(userId)) == nil
+}
+
+// pendingProposal returns the id of the open proposal the user is part of, if any.
+func (m *matchmaking) pendingProposal(ctx context.Context, userId string) string {
+	proposalId, err := m.cache.Get(ctx, userProposalKey(userId))
+	if err != nil || proposalId == "" {
+		return ""
+	}
+
+	closed, err := m.cache.Get(ctx, proposalClosedKey(proposalId))
+	if err != nil || closed != "" {
+		return ""
+	}
+	return proposalId
+}
+
+// propose asks the sorter for a group for the user and proposes it to every user of the group.
+func (m *matchmaking) propose(ctx context.Context, userId string, eventId string) ([]*domain.EventToPublish, error) {
+	sortResponse, err := m.sorterApi.Sort(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if sortResponse == nil || len(sortResponse.Users) == 0 {
+		return nil, nil
+	}
+
+	proposal := &domain.ChannelProposal{
+		ProposalId: uuid.New().String(),
+		Users:      sortResponse.Users,
+		Categories: sortResponse.Categories,
+		ExpiresAt:  time.Now().Add(m.config.Timeout),
+	}
+
+	value, err := json.Marshal(proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.cache.HSetWithTTL(ctx, proposalKey(proposal.ProposalId), proposalField, string(value), m.config.Timeout+proposalRetention)
+	if err != nil {
+		return nil, err
+	}
+
+	// A group with a user already in another proposal is dropped, the search asks the sorter again.
+	if !m.claim(ctx, proposal) {
+		m.cache.Delete(ctx, proposalKey(proposal.ProposalId))
+		return nil, nil
+	}
+
+	// Only the pod that created the proposal runs the timer. If it goes away, the next
+	// response after the deadline cancels the proposal instead.
+	time.AfterFunc(m.config.Timeout, func() {
+		m.expire(context.WithoutCancel(ctx), proposal.ProposalId)
+	})
+
+	events := make([]*domain.EventToPublish, 0, len(proposal.Users))
+	for _, memberId := range proposalUserIds(proposal) {
+		events = append(events, &domain.EventToPublish{
+			Event:   domain.CHANNEL_FOUND,
+			EventId: eventId,
+			UserId:  memberId,
+			Data:    proposal,
+		})
+	}
+	return events, nil
+}
+
+// claim links every user of the proposal to it, unless one of them is already in an open proposal,
+// in which case the links made so far are removed and it reports false.
+func (m *matchmaking) claim(ctx context.Context, proposal *domain.ChannelProposal) bool {
+	claimed := make([]string, 0, len(proposal.Users))
+	for _, memberId := range proposalUserIds(proposal) {
+		ok, err := m.cache.SetNXWithTTL(ctx, userProposalKey(memberId), proposal.ProposalId, m.config.Timeout+proposalRetention)
+		if err == nil && !ok && m.pendingProposal(ctx, memberId) == "" {
+			// The link left by a closed proposal doesn't hold the user.
+			m.cache.Delete(ctx, userProposalKey(memberId))
+			ok, err = m.cache.SetNXWithTTL(ctx, userProposalKey(memberId), proposal.ProposalId, m.config.Timeout+proposalRetention)
+		}
+
+		if err != nil || !ok {
+			for _, claimedId := range claimed {
+				m.cache.Delete(ctx, userProposalKey(claimedId))
+			}
+			return false
+		}
+		claimed = append(claimed, memberId)
+	}
+	return true
+}
+
+// Respond records the user's answer. A reject cancels the proposal right away, and the last
+// accept creates the channel through the backend.
+func (m *matchmaking) Respond(ctx context.Context, proposalId string, userId string, accepted bool, eventId string) ([]*domain.EventToPublish, error) {
+	proposal, responses, err := m.load(ctx, proposalId)
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := proposalUserIds(proposal)
+	if !slices.Contains(userIds, userId) {
+		return nil, ErrNotInProposal
+	}
+
+	closed, err := m.cache.Get(ctx, proposalClosedKey(proposalId))
+	if err != nil {
+		return nil, err
+	}
+	if closed != "" {
+		return nil, ErrProposalClosed
+	}
+
+	if time.Now().After(proposal.ExpiresAt) {
+		return m.cancel(ctx, proposal, responses, domain.CANCEL_REASON_TIMEOUT, "", eventId), nil
+	}
+
+	response := responseAccepted
+	if !accepted {
+		response = responseRejected
+	}
+
+	err = m.cache.HSetWithTTL(ctx, proposalKey(proposalId), userId, response, time.Until(proposal.ExpiresAt)+proposalRetention)
+	if err != nil {
+		return nil, err
+	}
+	responses[userId] = response
+
+	if !accepted {
+		return m.cancel(ctx, proposal, responses, domain.CANCEL_REASON_REJECTED, userId, eventId), nil
+	}
+
+	events := make([]*domain.EventToPublish, 0, len(userIds))
+	for _, memberId := range userIds {
+		events = append(events, &domain.EventToPublish{
+			Event:   domain.CHANNEL_ACCEPTED,
+			EventId: eventId,
+			UserId:  memberId,
+			Data: domain.ChannelResponded{
+				ProposalId: proposalId,
+				UserId:     userId,
+			},
+		})
+	}
+
+	// Responses handled by other pods are only visible through the cache, so they're read again.
+	_, responses, err = m.load(ctx, proposalId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, memberId := range userIds {
+		if responses[memberId] != responseAccepted {
+			return events, nil
+		}
+	}
+
+	return append(events, m.create(ctx, proposal, eventId)...), nil
+}
+
+func (m *matchmaking) create(ctx context.Context, proposal *domain.ChannelProposal, eventId string) []*domain.EventToPublish {
+	if !m.close(ctx, proposal) {
+		return nil
+	}
+
+	userIds := proposalUserIds(proposal)
+	headers := map[string]string{
+		"X-Request-Id": eventId,
+	}
+
+	channel, err := m.channelsApi.CreateChannel(ctx, domain.ChannelRequest{
+		Members:    userIds,
+		Categories: proposal.Categories,
+	}, headers)
+	if err == nil && channel == nil {
+		err = errors.New("empty channel response")
+	}
+	if err != nil {
+		fmt.Println(util.FailedToCreateChannel, err)
+		return cancelledEvents(proposal, domain.ChannelCancelled{
+			ProposalId: proposal.ProposalId,
+			Reason:     domain.CANCEL_REASON_FAILED,
+		}, eventId)
+	}
+
+	events := make([]*domain.EventToPublish, 0, len(userIds))
+	for _, userId := range userIds {
+		events = append(events, &domain.EventToPublish{
+			Event:   domain.CHANNEL_CREATED,
+			EventId: eventId,
+			UserId:  userId,
+			Data: domain.ChannelCreated{
+				ProposalId: proposal.ProposalId,
+				ChannelId:  channel.ChannelId,
+				Members:    userIds,
+				Categories: proposal.Categories,
+			},
+		})
+	}
+	return events
+}
+
+func (m *matchmaking) cancel(ctx context.Context, proposal *domain.ChannelProposal, responses map[string]string, reason string, rejectedBy string, eventId string) []*domain.EventToPublish {
+	if !m.close(ctx, proposal) {
+		return nil
+	}
+
+	events := cancelledEvents(proposal, domain.ChannelCancelled{
+		ProposalId: proposal.ProposalId,
+		Reason:     reason,
+		RejectedBy: rejectedBy,
+		Requeued:   m.config.Requeue,
+	}, eventId)
+
+	if !m.config.Requeue {
+		return events
+	}
+
+	// A single search may already place several of the accepted users in a new proposal.
+	requeued := make(map[string]bool)
+	for _, userId := range proposalUserIds(proposal) {
+		if responses[userId] != responseAccepted || requeued[userId] {
+			continue
+		}
+
+		found, err := m.Search(ctx, userId, eventId)
+		if err != nil {
+			fmt.Println(util.FailedToRequeueUser, err)
+			continue
+		}
+
+		for _, event := range found {
+			requeued[event.UserId] = true
+		}
+		events = append(events, found...)
+	}
+	return events
+}
+
+func (m *matchmaking) expire(ctx context.Context, proposalId string) {
+	proposal, responses, err := m.load(ctx, proposalId)
+	if err != nil {
+		return
+	}
+
+	m.eventDispatcher.Dispatch(ctx, m.cancel(ctx, proposal, responses, domain.CANCEL_REASON_TIMEOUT, "", ""))
+}
+
+// close marks the proposal as finished, reporting false when another response, pod or timer got
+// there first. Closing it also ends the searches of its users.
+func (m *matchmaking) close(ctx context.Context, proposal *domain.ChannelProposal) bool {
+	closed, err := m.cache.Incr(ctx, proposalClosedKey(proposal.ProposalId))
+	if err != nil {
+		fmt.Println(util.FailedToCloseProposal, err)
+		return false
+	}
+	m.cache.Expire(ctx, proposalClosedKey(proposal.ProposalId), m.config.Timeout+proposalRetention)
+
+	if closed != 1 {
+		return false
+	}
+
+	for _, userId := range proposalUserIds(proposal) {
+		m.cache.Delete(ctx, searchSessionKey(userId))
+	}
+	return true
+}
+
+func (m *matchmaking) load(ctx context.Context, proposalId string) (*domain.ChannelProposal, map[string]string, error) {
+	responses, err := m.cache.HGetAll(ctx, proposalKey(proposalId))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	value, ok := responses[proposalField]
+	if !ok {
+		return nil, nil, ErrProposalNotFound
+	}
+	delete(responses, proposalField)
+
+	proposal := &domain.ChannelProposal{}
+	err = json.Unmarshal([]byte(value), proposal)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proposal, responses, nil
+}
+
+func cancelledEvents(proposal *domain.ChannelProposal, cancelled domain.ChannelCancelled, eventId string) []*domain.EventToPublish {
+	events := make([]*domain.EventToPublish, 0, len(proposal.Users))
+	for _, userId := range proposalUserIds(proposal) {
+		events = append(events, &domain.EventToPublish{
+			Event:   domain.CHANNEL_CANCELLED,
+			EventId: eventId,
+			UserId:  userId,
+			Data:    cancelled,
+		})
+	}
+	return events
+}
+
+func proposalUserIds(proposal *domain.ChannelProposal) []string {
+	userIds := make([]string, 0, len(proposal.Users))
+	for _, user := range proposal.Users {
+		userIds = append(userIds, user.Id)
+	}
+	return userIds
+}
+
+func proposalKey(proposalId string) string {
+	return "channel_proposal:" + proposalId
+}
+
+func proposalClosedKey(proposalId string) string {
+	return "channel_proposal_closed:" + proposalId
+}
+
+func userProposalKey(userId string) string {
+	return "channel_proposal_user:" + userId
+}
+
+func searchSessionKey(userId string) string {
+	return "search_session:" + userId
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+)
+
+const (
+	defaultOfflineInboxTTL       = 7 * 24 * time.Hour
+	defaultOfflineInboxMaxLength = 100
+)
+
+// OfflineInbox keeps the events addressed to users that are not connected to any pod,
+// so they can be delivered in order once the user reconnects.
+type OfflineInbox interface {
+	Store(ctx context.Context, event *domain.EventToPublish) error
+	Drain(ctx context.Context, userId string) ([]*domain.EventToPublish, error)
+}
+
+type offlineInbox struct {
+	cache     cache.Cache
+	ttl       time.Duration
+	maxLength int64
+}
+
+func NewOfflineInbox(cache cache.Cache, ttl time.Duration, maxLength int64) OfflineInbox {
+	if ttl <= 0 {
+		ttl = defaultOfflineInboxTTL
+	}
+
+	if maxLength <= 0 {
+		maxLength = defaultOfflineInboxMaxLength
+	}
+
+	return &offlineInbox{
+		cache:     cache,
+		ttl:       ttl,
+		maxLength: maxLength,
+	}
+}
+
+func (i *offlineInbox) Store(ctx context.Context, event *domain.EventToPublish) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return i.cache.ListPush(ctx, offlineInboxKey(event.UserId), string(value), i.maxLength, i.ttl)
+}
+
+// Drain removes and returns the user's stored events, oldest first.
+func (i *offlineInbox) Drain(ctx context.Context, userId string) ([]*domain.EventToPublish, error) {
+	values, err := i.cache.ListPopAll(ctx, offlineInboxKey(userId))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*domain.EventToPublish, 0, len(values))
+	for _, value := range values {
+		event := domain.EventToPublish{}
+		if err := json.Unmarshal([]byte(value), &event); err != nil {
+			continue
+		}
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+func offlineInboxKey(userId string) string {
+	return "offline_inbox:" + userId
+}
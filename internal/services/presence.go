@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+)
+
+// Presence records which pods hold each user's connections. Entries expire shortly after the
+// heartbeat stops refreshing them, so the users of a crashed pod don't stay online forever.
+type Presence interface {
+	Connect(ctx context.Context, userId string, connId string) error
+	Refresh(ctx context.Context, userId string, connId string) error
+	Disconnect(ctx context.Context, userId string, connId string) error
+	IsOnline(ctx context.Context, userId string) (bool, error)
+	GetPod(ctx context.Context, userId string) (string, error)
+	GetPods(ctx context.Context, userId string) ([]string, error)
+	GetPresence(ctx context.Context, userIds []string) (map[string][]string, error)
+}
+
+type presence struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+func NewPresence(cache cache.Cache, ttl time.Duration) Presence {
+	return &presence{
+		cache: cache,
+		ttl:   ttl,
+	}
+}
+
+func (p *presence) Connect(ctx context.Context, userId string, connId string) error {
+	return p.Refresh(ctx, userId, connId)
+}
+
+// Refresh marks the connection as alive in this pod, it's called on every pong.
+func (p *presence) Refresh(ctx context.Context, userId string, connId string) error {
+	entry := POD_NAME + "|" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return p.cache.HSetWithTTL(ctx, userConnectionsKey(userId), connId, entry, p.ttl)
+}
+
+func (p *presence) Disconnect(ctx context.Context, userId string, connId string) error {
+	return p.cache.HDel(ctx, userConnectionsKey(userId), connId)
+}
+
+func (p *presence) IsOnline(ctx context.Context, userId string) (bool, error) {
+	pods, err := p.GetPods(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+	return len(pods) > 0, nil
+}
+
+// GetPod returns one of the pods holding the user's connections, or an empty string when the user is offline.
+func (p *presence) GetPod(ctx context.Context, userId string) (string, error) {
+	pods, err := p.GetPods(ctx, userId)
+	if err != nil || len(pods) == 0 {
+		return "", err
+	}
+	return pods[0], nil
+}
+
+// GetPods returns the distinct pods holding at least one live connection of the user.
+func (p *presence) GetPods(ctx context.Context, userId string) ([]string, error) {
+	userConns, err := p.cache.HGetAll(ctx, userConnectionsKey(userId))
+	if err != nil {
+		return nil, err
+	}
+	return p.livePods(userConns), nil
+}
+
+// GetPresence returns the pods of every online user among userIds, offline users are left out.
+func (p *presence) GetPresence(ctx context.Context, userIds []string) (map[string][]string, error) {
+	keys := make([]string, 0, len(userIds))
+	for _, userId := range userIds {
+		keys = append(keys, userConnectionsKey(userId))
+	}
+
+	values, err := p.cache.HGetAllMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	online := make(map[string][]string)
+	for i, userConns := range values {
+		if pods := p.livePods(userConns); len(pods) > 0 {
+			online[userIds[i]] = pods
+		}
+	}
+	return online, nil
+}
+
+// livePods skips the connections whose last heartbeat is older than the ttl. The hash ttl alone
+// is not enough, since any live device of the user keeps it from expiring.
+func (p *presence) livePods(userConns map[string]string) []string {
+	seen := make(map[string]bool)
+	pods := make([]string, 0, len(userConns))
+	for _, entry := range userConns {
+		podName, _, _ := strings.Cut(entry, "|")
+		if podName == "" || seen[podName] || !p.isLive(entry) {
+			continue
+		}
+
+		seen[podName] = true
+		pods = append(pods, podName)
+	}
+	return pods
+}
+
+func (p *presence) isLive(entry string) bool {
+	_, lastSeen, _ := strings.Cut(entry, "|")
+	lastSeenMilli, err := strconv.ParseInt(lastSeen, 10, 64)
+	if err != nil {
+		return true
+	}
+	return time.Since(time.UnixMilli(lastSeenMilli)) <= p.ttl
+}
+
+func userConnectionsKey(userId string) string {
+	return "connections:" + userId
+}
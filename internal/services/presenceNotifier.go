@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultOfflineDebounce = 10 * time.Second
+	defaultSubscriptionTTL = 24 * time.Hour
+)
+
+var ErrPresenceForbidden = errors.New("websocket_handler: subscription to the presence of a user outside the channel is not allowed")
+
+// PresenceNotifier tells the users subscribed to someone's presence when that user comes online
+// or goes offline. Going offline is debounced, so a quick reconnect produces no events at all.
+// Subscriptions are dropped once the subscriber goes offline, and expire after subscriptionTTL
+// in case the pod that would have dropped them is gone.
+type PresenceNotifier interface {
+	// Subscribe returns ErrPresenceForbidden unless the subscriber and every user are members of the channel.
+	Subscribe(ctx context.Context, subscriberId string, channelId string, userIds []string) ([]*domain.EventToPublish, error)
+	Unsubscribe(ctx context.Context, subscriberId string, userIds []string) error
+	UserConnected(ctx context.Context, userId string)
+	UserDisconnected(ctx context.Context, userId string)
+}
+
+type presenceNotifier struct {
+	cache             cache.Cache
+	presence          Presence
+	eventDispatcher   EventDispatcher
+	channelMembership ChannelMembership
+	offlineDebounce   time.Duration
+	subscriptionTTL   time.Duration
+}
+
+func NewPresenceNotifier(cache cache.Cache, presence Presence, eventDispatcher EventDispatcher, channelMembership ChannelMembership, offlineDebounce time.Duration, subscriptionTTL time.Duration) PresenceNotifier {
+	if offlineDebounce <= 0 {
+		offlineDebounce = defaultOfflineDebounce
+	}
+
+	if subscriptionTTL <= 0 {
+		subscriptionTTL = defaultSubscriptionTTL
+	}
+
+	return &presenceNotifier{
+		cache:             cache,
+		presence:          presence,
+		eventDispatcher:   eventDispatcher,
+		channelMembership: channelMembership,
+		offlineDebounce:   offlineDebounce,
+		subscriptionTTL:   subscriptionTTL,
+	}
+}
+
+// Subscribe registers the subscriber to the presence of userIds and returns their current presence.
+func (n *presenceNotifier) Subscribe(ctx context.Context, subscriberId string, channelId string, userIds []string) ([]*domain.EventToPublish, error) {
+	members, err := n.channelMembership.Authorize(ctx, channelId, subscriberId)
+	if errors.Is(err, ErrNotChannelMember) {
+		return nil, ErrPresenceForbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userId := range userIds {
+		if !slices.Contains(members, userId) {
+			return nil, ErrPresenceForbidden
+		}
+	}
+
+	for _, userId := range userIds {
+		err := n.subscribe(ctx, subscriberId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	online, err := n.presence.GetPresence(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*domain.EventToPublish, 0, len(userIds))
+	for _, userId := range userIds {
+		event := domain.USER_OFFLINE
+		if _, ok := online[userId]; ok {
+			event = domain.USER_ONLINE
+		}
+		events = append(events, presenceEvent(event, subscriberId, userId))
+	}
+	return events, nil
+}
+
+func (n *presenceNotifier) Unsubscribe(ctx context.Context, subscriberId string, userIds []string) error {
+	for _, userId := range userIds {
+		_, err := n.cache.SRem(ctx, presenceSubscribersKey(userId), subscriberId)
+		if err != nil {
+			return err
+		}
+
+		_, err = n.cache.SRem(ctx, presenceSubscriptionsKey(subscriberId), userId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribe adds the subscriber to the subscribers of the user, and the user to the subscriptions
+// of the subscriber so they can be dropped when it goes offline, resetting the ttl of both sets.
+func (n *presenceNotifier) subscribe(ctx context.Context, subscriberId string, userId string) error {
+	_, err := n.cache.SAdd(ctx, presenceSubscribersKey(userId), subscriberId)
+	if err != nil {
+		return err
+	}
+
+	err = n.cache.Expire(ctx, presenceSubscribersKey(userId), n.subscriptionTTL)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.cache.SAdd(ctx, presenceSubscriptionsKey(subscriberId), userId)
+	if err != nil {
+		return err
+	}
+
+	return n.cache.Expire(ctx, presenceSubscriptionsKey(subscriberId), n.subscriptionTTL)
+}
+
+// unsubscribeAll drops every subscription of a subscriber that went offline.
+func (n *presenceNotifier) unsubscribeAll(ctx context.Context, subscriberId string) {
+	userIds, err := n.cache.SMembers(ctx, presenceSubscriptionsKey(subscriberId))
+	if err != nil {
+		fmt.Println(util.FailedToNotifyPresence, err)
+		return
+	}
+
+	for _, userId := range userIds {
+		n.cache.SRem(ctx, presenceSubscribersKey(userId), subscriberId)
+	}
+	n.cache.Delete(ctx, presenceSubscriptionsKey(subscriberId))
+}
+
+// UserConnected must be called after the connection is registered. It cancels any pending USER_OFFLINE,
+// and subscribers are notified only when they were not told the user is online yet. Concurrent
+// connections race on the announced key, so exactly one of them notifies.
+func (n *presenceNotifier) UserConnected(ctx context.Context, userId string) {
+	err := n.cache.Delete(ctx, offlinePendingKey(userId))
+	if err != nil {
+		fmt.Println(util.FailedToNotifyPresence, err)
+		return
+	}
+
+	announced, err := n.cache.SetNXWithTTL(ctx, onlineAnnouncedKey(userId), "1", n.subscriptionTTL)
+	if err != nil || !announced {
+		return
+	}
+
+	n.notify(ctx, domain.USER_ONLINE, userId)
+}
+
+// UserDisconnected must be called after the connection is removed. When the user has no other
+// connection the USER_OFFLINE event is sent after the debounce, unless the user reconnected meanwhile,
+// and the user's own subscriptions are dropped.
+func (n *presenceNotifier) UserDisconnected(ctx context.Context, userId string) {
+	ctx = context.WithoutCancel(ctx)
+
+	online, err := n.presence.IsOnline(ctx, userId)
+	if err != nil || online {
+		return
+	}
+
+	// Every disconnect gets its own token, so the timer of an earlier disconnect followed by a
+	// reconnect and another disconnect doesn't fire before the debounce of the last one.
+	token := uuid.New().String()
+	err = n.cache.SetWithTTL(ctx, offlinePendingKey(userId), token, 2*n.offlineDebounce)
+	if err != nil {
+		fmt.Println(util.FailedToNotifyPresence, err)
+		return
+	}
+
+	time.AfterFunc(n.offlineDebounce, func() {
+		offlinePending, err := n.cache.Get(ctx, offlinePendingKey(userId))
+		if err != nil || offlinePending != token {
+			return
+		}
+
+		if online, err := n.presence.IsOnline(ctx, userId); err != nil || online {
+			return
+		}
+
+		n.cache.Delete(ctx, offlinePendingKey(userId))
+		n.cache.Delete(ctx, onlineAnnouncedKey(userId))
+		n.notify(ctx, domain.USER_OFFLINE, userId)
+		n.unsubscribeAll(ctx, userId)
+	})
+}
+
+func (n *presenceNotifier) notify(ctx context.Context, event string, userId string) {
+	subscribers, err := n.cache.SMembers(ctx, presenceSubscribersKey(userId))
+	if err != nil {
+		fmt.Println(util.FailedToNotifyPresence, err)
+		return
+	}
+
+	events := make([]*domain.EventToPublish, 0, len(subscribers))
+	for _, subscriberId := range subscribers {
+		events = append(events, presenceEvent(event, subscriberId, userId))
+	}
+	n.eventDispatcher.Dispatch(ctx, events)
+}
+
+func presenceEvent(event string, subscriberId string, userId string) *domain.EventToPublish {
+	return &domain.EventToPublish{
+		Event:  event,
+		UserId: subscriberId,
+		Data: domain.PresenceChanged{
+			UserId: userId,
+			At:     time.Now(),
+		},
+		Ephemeral: true,
+	}
+}
+
+func presenceSubscribersKey(userId string) string {
+	return "presence_subscribers:" + userId
+}
+
+func presenceSubscriptionsKey(subscriberId string) string {
+	return "presence_subscriptions:" + subscriberId
+}
+
+func offlinePendingKey(userId string) string {
+	return "presence_offline_pending:" + userId
+}
+
+func onlineAnnouncedKey(userId string) string {
+	return "presence_online_announced:" + userId
+}
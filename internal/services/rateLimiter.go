@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+)
+
+// RateLimit lets a user send Limit events of a type per Window, in bursts of up to Limit events.
+type RateLimit struct {
+	Limit  int64
+	Window time.Duration
+}
+
+// ParseRateLimits parses the limit of every event type, written as "<limit>/<window>", e.g. "30/1m".
+func ParseRateLimits(limits map[string]string) (map[string]RateLimit, error) {
+	rateLimits := make(map[string]RateLimit, len(limits))
+	for eventType, value := range limits {
+		rawLimit, rawWindow, _ := strings.Cut(value, "/")
+
+		limit, err := strconv.ParseInt(rawLimit, 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("websocket_handler: invalid rate limit of %s: %q", eventType, value)
+		}
+
+		window, err := time.ParseDuration(rawWindow)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("websocket_handler: invalid rate limit window of %s: %q", eventType, value)
+		}
+
+		rateLimits[eventType] = RateLimit{Limit: limit, Window: window}
+	}
+	return rateLimits, nil
+}
+
+// RateLimiter limits how many events of each type a user can send, with token buckets
+// kept in redis so the limit holds across every connection of the user on every pod.
+type RateLimiter interface {
+	// Allow takes a token of the user's bucket for the event type, returning how long until
+	// there is one when it is empty. Event types without a limit are always allowed.
+	Allow(ctx context.Context, userId string, eventType string) (bool, time.Duration, error)
+}
+
+type rateLimiter struct {
+	cache  cache.Cache
+	limits map[string]RateLimit
+}
+
+func NewRateLimiter(cache cache.Cache, limits map[string]RateLimit) RateLimiter {
+	return &rateLimiter{
+		cache:  cache,
+		limits: limits,
+	}
+}
+
+func (r *rateLimiter) Allow(ctx context.Context, userId string, eventType string) (bool, time.Duration, error) {
+	limit, ok := r.limits[eventType]
+	if !ok {
+		return true, 0, nil
+	}
+
+	rate := float64(limit.Limit) / limit.Window.Seconds()
+	return r.cache.TakeToken(ctx, rateLimitKey(userId, eventType), rate, limit.Limit)
+}
+
+func rateLimitKey(userId string, eventType string) string {
+	return "rate_limit:" + userId + ":" + eventType
+}
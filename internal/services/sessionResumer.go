@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultReplayBufferSize = 200
+	defaultReplayBufferTTL  = 5 * time.Minute
+)
+
+// SessionResumer numbers every event written to a user and keeps the latest ones in a bounded
+// replay buffer, so a client reconnecting after a short disconnect gets what it missed.
+type SessionResumer interface {
+	Sequence(ctx context.Context, event *domain.EventToPublish) error
+	Resume(ctx context.Context, userId string, resumeToken string, lastSeq int64) (*domain.SessionStarted, []*domain.EventToPublish, error)
+}
+
+type sessionResumer struct {
+	cache      cache.Cache
+	bufferSize int64
+	bufferTTL  time.Duration
+}
+
+func NewSessionResumer(cache cache.Cache, bufferSize int64, bufferTTL time.Duration) SessionResumer {
+	if bufferSize <= 0 {
+		bufferSize = defaultReplayBufferSize
+	}
+
+	if bufferTTL <= 0 {
+		bufferTTL = defaultReplayBufferTTL
+	}
+
+	return &sessionResumer{
+		cache:      cache,
+		bufferSize: bufferSize,
+		bufferTTL:  bufferTTL,
+	}
+}
+
+// Sequence assigns the next user sequence number to the event and appends it to the replay buffer,
+// both at once so the buffer holds every event numbered so far in order. The buffered event is
+// stored without its number, which prefixes it instead.
+func (r *sessionResumer) Sequence(ctx context.Context, event *domain.EventToPublish) error {
+	event.Seq = 0
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	seq, err := r.cache.ListPushSequenced(ctx, sequenceKey(event.UserId), replayBufferKey(event.UserId), string(value), r.bufferSize, r.bufferTTL)
+	if err != nil {
+		return err
+	}
+	event.Seq = seq
+	return nil
+}
+
+// Resume returns the session the client must store to resume later and, when the client sent a
+// resume token, the buffered events newer than lastSeq. If the buffer no longer holds every missed
+// event the session is flagged as requiring a full resync.
+func (r *sessionResumer) Resume(ctx context.Context, userId string, resumeToken string, lastSeq int64) (*domain.SessionStarted, []*domain.EventToPublish, error) {
+	streamId, err := r.streamId(ctx, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	currentSeq, err := r.currentSeq(ctx, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session := &domain.SessionStarted{
+		ResumeToken: streamId,
+		LastSeq:     currentSeq,
+	}
+
+	if resumeToken == "" {
+		return session, nil, nil
+	}
+
+	if resumeToken != streamId || lastSeq > currentSeq {
+		session.ResyncRequired = true
+		return session, nil, nil
+	}
+
+	if lastSeq == currentSeq {
+		session.Resumed = true
+		return session, nil, nil
+	}
+
+	values, err := r.cache.ListRange(ctx, replayBufferKey(userId))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missed := make([]*domain.EventToPublish, 0, len(values))
+	for _, value := range values {
+		rawSeq, rawEvent, _ := strings.Cut(value, ":")
+		seq, err := strconv.ParseInt(rawSeq, 10, 64)
+		if err != nil || seq <= lastSeq {
+			continue
+		}
+
+		event := domain.EventToPublish{}
+		if err := json.Unmarshal([]byte(rawEvent), &event); err != nil {
+			continue
+		}
+		event.Seq = seq
+		missed = append(missed, &event)
+	}
+
+	if len(missed) == 0 || missed[0].Seq > lastSeq+1 {
+		session.ResyncRequired = true
+		return session, nil, nil
+	}
+
+	session.Resumed = true
+	return session, missed, nil
+}
+
+func (r *sessionResumer) streamId(ctx context.Context, userId string) (string, error) {
+	streamId, err := r.cache.Get(ctx, sequenceStreamKey(userId))
+	if err != nil {
+		return "", err
+	}
+
+	if streamId != "" {
+		return streamId, nil
+	}
+
+	// Connections of the user on other pods may be creating it too, the first one wins. A zero ttl never expires.
+	streamId = uuid.New().String()
+	created, err := r.cache.SetNXWithTTL(ctx, sequenceStreamKey(userId), streamId, 0)
+	if err != nil {
+		return "", err
+	}
+	if !created {
+		return r.cache.Get(ctx, sequenceStreamKey(userId))
+	}
+	return streamId, nil
+}
+
+func (r *sessionResumer) currentSeq(ctx context.Context, userId string) (int64, error) {
+	value, err := r.cache.Get(ctx, sequenceKey(userId))
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func sequenceKey(userId string) string {
+	return "sequence:" + userId
+}
+
+func sequenceStreamKey(userId string) string {
+	return "sequence_stream:" + userId
+}
+
+func replayBufferKey(userId string) string {
+	return "replay_buffer:" + userId
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/internal/http/domain"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/pubsubconnector"
+	"github.com/ADAGroupTcc/ms-realtime-handler-api/util"
+)
+
+var ErrTopicForbidden = errors.New("websocket_handler: subscription to topic is not allowed")
+
+// TopicAuthorizer decides whether the user may subscribe to the topic, returning an error when not.
+type TopicAuthorizer func(ctx context.Context, userId string, topic string) error
+
+// Topics is a publish/subscribe mechanism for named topics, e.g. "channel:<id>" or "announcements".
+// Each pod indexes the subscriptions of its own connections, and every published event is broadcast
+// to all pods, which write it to their local subscribers.
+type Topics interface {
+	// Authorize registers the authorizer of the topics named exactly topic or starting with "<topic>:".
+	// Topics without an authorizer can't be subscribed to.
+	Authorize(topic string, authorizer TopicAuthorizer)
+	Subscribe(ctx context.Context, activeConn *ActiveConn, topics []string) ([]string, error)
+	Unsubscribe(activeConn *ActiveConn, topics []string)
+	UnsubscribeAll(activeConn *ActiveConn)
+	Publish(ctx context.Context, event *domain.EventToPublish) error
+	Listen(ctx context.Context)
+}
+
+type topics struct {
+	broker      *pubsubconnector.PubSubBroker
+	topicPrefix string
+
+	authorizersMu sync.RWMutex
+	authorizers   map[string]TopicAuthorizer
+
+	mu            sync.RWMutex
+	subscribers   map[string]map[string]*ActiveConn
+	subscriptions map[string]map[string]struct{}
+}
+
+func NewTopics(broker *pubsubconnector.PubSubBroker, topicPrefix string) Topics {
+	return &topics{
+		broker:        broker,
+		topicPrefix:   topicPrefix,
+		authorizers:   make(map[string]TopicAuthorizer),
+		subscribers:   make(map[string]map[string]*ActiveConn),
+		subscriptions: make(map[string]map[string]struct{}),
+	}
+}
+
+// AllowTopic is the TopicAuthorizer of public topics.
+func AllowTopic(ctx context.Context, userId string, topic string) error {
+	return nil
+}
+
+// ChannelTopicAuthorizer only lets channel members subscribe to "channel:<id>".
+func ChannelTopicAuthorizer(channelMembership ChannelMembership) TopicAuthorizer {
+	return func(ctx context.Context, userId string, topic string) error {
+		_, channelId, _ := strings.Cut(topic, ":")
+		if channelId == "" {
+			return ErrTopicForbidden
+		}
+
+		_, err := channelMembership.Authorize(ctx, channelId, userId)
+		if errors.Is(err, ErrNotChannelMember) {
+			return ErrTopicForbidden
+		}
+		return err
+	}
+}
+
+func (t *topics) Authorize(topic string, authorizer TopicAuthorizer) {
+	t.authorizersMu.Lock()
+	defer t.authorizersMu.Unlock()
+	t.authorizers[topic] = authorizer
+}
+
+// Subscribe subscribes the connection to every topic it is authorized to, returning them.
+// The error tells why the last refused topic was refused. A closed connection isn't subscribed,
+// so a SUBSCRIBE still being handled when it closes can't outlive UnsubscribeAll.
+func (t *topics) Subscribe(ctx context.Context, activeConn *ActiveConn, topics []string) ([]string, error) {
+	var refused error
+	allowed := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		err := t.authorize(ctx, activeConn.UserId, topic)
+		if err != nil {
+			refused = fmt.Errorf("%s: %w", topic, err)
+			continue
+		}
+		allowed = append(allowed, topic)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	select {
+	case <-activeConn.Done():
+		return nil, ErrConnectionClosed
+	default:
+	}
+
+	for _, topic := range allowed {
+		if t.subscribers[topic] == nil {
+			t.subscribers[topic] = make(map[string]*ActiveConn)
+		}
+		t.subscribers[topic][activeConn.Id] = activeConn
+
+		if t.subscriptions[activeConn.Id] == nil {
+			t.subscriptions[activeConn.Id] = make(map[string]struct{})
+		}
+		t.subscriptions[activeConn.Id][topic] = struct{}{}
+	}
+	return allowed, refused
+}
+
+func (t *topics) Unsubscribe(activeConn *ActiveConn, topics []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, topic := range topics {
+		t.unsubscribe(activeConn.Id, topic)
+	}
+}
+
+// UnsubscribeAll must be called once the connection is closed.
+func (t *topics) UnsubscribeAll(activeConn *ActiveConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for topic := range t.subscriptions[activeConn.Id] {
+		t.unsubscribe(activeConn.Id, topic)
+	}
+}
+
+func (t *topics) unsubscribe(connId string, topic string) {
+	delete(t.subscribers[topic], connId)
+	if len(t.subscribers[topic]) == 0 {
+		delete(t.subscribers, topic)
+	}
+
+	delete(t.subscriptions[connId], topic)
+	if len(t.subscriptions[connId]) == 0 {
+		delete(t.subscriptions, connId)
+	}
+}
+
+// Publish broadcasts the event to the subscribers of event.Topic on every pod. Topic events are ephemeral.
+func (t *topics) Publish(ctx context.Context, event *domain.EventToPublish) error {
+	if event.Topic == "" {
+		return errors.New("websocket_handler: event has no topic")
+	}
+
+	return t.broker.Publisher.Publish(ctx, event, &map[string]interface{}{
+		"topic": t.broadcastTopic(),
+	})
+}
+
+// Listen subscribes to the broadcast topic and writes every received event to the local subscribers.
+// It blocks until the context is cancelled.
+func (t *topics) Listen(ctx context.Context) {
+	eventsChan := make(chan []byte)
+	go t.broker.Subscriber.SubscribeAsync(ctx, t.broadcastTopic(), eventsChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-eventsChan:
+			event := domain.EventToPublish{}
+			err := json.Unmarshal(msg, &event)
+			if err != nil {
+				fmt.Println(util.UnableToParseEventResponse, err)
+				continue
+			}
+
+			t.deliverLocal(&event)
+		}
+	}
+}
+
+func (t *topics) deliverLocal(event *domain.EventToPublish) {
+	t.mu.RLock()
+	subscribers := make([]*ActiveConn, 0, len(t.subscribers[event.Topic]))
+	for _, activeConn := range t.subscribers[event.Topic] {
+		subscribers = append(subscribers, activeConn)
+	}
+	t.mu.RUnlock()
+
+	for _, activeConn := range subscribers {
+		subscriberEvent := *event
+		subscriberEvent.UserId = activeConn.UserId
+		subscriberEvent.Ephemeral = true
+
+		err := activeConn.Send(&subscriberEvent)
+		if err != nil && !errors.Is(err, ErrConnectionClosed) {
+			fmt.Println(util.FailedToSendEventToUser, activeConn.UserId, err)
+		}
+	}
+}
+
+func (t *topics) authorize(ctx context.Context, userId string, topic string) error {
+	prefix, _, _ := strings.Cut(topic, ":")
+
+	t.authorizersMu.RLock()
+	authorizer, ok := t.authorizers[topic]
+	if !ok {
+		authorizer, ok = t.authorizers[prefix]
+	}
+	t.authorizersMu.RUnlock()
+
+	if !ok || topic == "" {
+		return ErrTopicForbidden
+	}
+	return authorizer(ctx, userId, topic)
+}
+
+func (t *topics) broadcastTopic() string {
+	return fmt.Sprintf("%s:%s", t.topicPrefix, "topics")
+}
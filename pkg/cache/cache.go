@@ -1,9 +1,45 @@
 package cache
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Cache interface {
 	Set(ctx context.Context, key string, value string) error
+	SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error
+	// SetNXWithTTL sets the key only when it does not exist yet, reporting whether it was set.
+	SetNXWithTTL(ctx context.Context, key string, value string, ttl time.Duration) (bool, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
 	Delete(ctx context.Context, key string) error
+	Incr(ctx context.Context, key string) (int64, error)
+	HSet(ctx context.Context, key string, field string, value string) error
+	// HSetWithTTL sets the field and resets the ttl of the whole hash.
+	HSetWithTTL(ctx context.Context, key string, field string, value string, ttl time.Duration) error
+	// HSetIfExistsWithTTL sets the field only when it is already in the hash, reporting whether it was,
+	// and resets the ttl of the whole hash when it was.
+	HSetIfExistsWithTTL(ctx context.Context, key string, field string, value string, ttl time.Duration) (bool, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	// HGetAllMany returns the hashes of every key, in the same order, in a single round trip.
+	HGetAllMany(ctx context.Context, keys []string) ([]map[string]string, error)
+	HDel(ctx context.Context, key string, field string) error
+	HLen(ctx context.Context, key string) (int64, error)
+	// SAdd adds member to the set, reporting whether it was not there yet.
+	SAdd(ctx context.Context, key string, member string) (bool, error)
+	// SRem removes member from the set, reporting whether it was there.
+	SRem(ctx context.Context, key string, member string) (bool, error)
+	SMembers(ctx context.Context, key string) ([]string, error)
+	// ListPush appends value to the list, keeping only its last maxLen items and resetting its ttl.
+	ListPush(ctx context.Context, key string, value string, maxLen int64, ttl time.Duration) error
+	// ListPushSequenced atomically increments counterKey and appends value to the list prefixed with
+	// the new count and a colon, e.g. "42:value", keeping only its last maxLen items and resetting its ttl.
+	ListPushSequenced(ctx context.Context, counterKey string, key string, value string, maxLen int64, ttl time.Duration) (int64, error)
+	// ListRange returns every item of the list, oldest first.
+	ListRange(ctx context.Context, key string) ([]string, error)
+	// ListPopAll atomically returns every item of the list, oldest first, and removes the list.
+	ListPopAll(ctx context.Context, key string) ([]string, error)
+	// TakeToken takes a token from the bucket refilled with rate tokens per second up to burst tokens,
+	// reporting whether there was one and, when not, how long until there is.
+	TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, time.Duration, error)
 }
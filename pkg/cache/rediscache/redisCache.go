@@ -2,6 +2,7 @@ package rediscache
 
 import (
 	"context"
+	"time"
 
 	"github.com/ADAGroupTcc/ms-realtime-handler-api/pkg/cache"
 	"github.com/go-redis/redis/v8"
@@ -36,6 +37,20 @@ func (c *redisCache) Set(ctx context.Context, key string, value string) error {
 	return err
 }
 
+func (c *redisCache) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	err := c.client.Set(ctx, key, value, ttl).Err()
+	return err
+}
+
+func (c *redisCache) SetNXWithTTL(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (c *redisCache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	err := c.client.Expire(ctx, key, ttl).Err()
+	return err
+}
+
 func (c *redisCache) Get(ctx context.Context, key string) (string, error) {
 	val, err := c.client.Get(ctx, key).Result()
 	if err == redis.Nil {
@@ -51,3 +66,181 @@ func (c *redisCache) Delete(ctx context.Context, key string) error {
 	err := c.client.Del(ctx, key).Err()
 	return err
 }
+
+func (c *redisCache) Incr(ctx context.Context, key string) (int64, error) {
+	val, err := c.client.Incr(ctx, key).Result()
+	return val, err
+}
+
+func (c *redisCache) HSet(ctx context.Context, key string, field string, value string) error {
+	err := c.client.HSet(ctx, key, field, value).Err()
+	return err
+}
+
+func (c *redisCache) HSetWithTTL(ctx context.Context, key string, field string, value string, ttl time.Duration) error {
+	pipe := c.client.TxPipeline()
+	pipe.HSet(ctx, key, field, value)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// hSetIfExistsScript checks the field and sets it within a single command, so a field deleted
+// meanwhile is not written back.
+var hSetIfExistsScript = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1
+`)
+
+func (c *redisCache) HSetIfExistsWithTTL(ctx context.Context, key string, field string, value string, ttl time.Duration) (bool, error) {
+	set, err := hSetIfExistsScript.Run(ctx, c.client, []string{key}, field, value, ttl.Milliseconds()).Int64()
+	return set == 1, err
+}
+
+func (c *redisCache) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	val, err := c.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (c *redisCache) HGetAllMany(ctx context.Context, keys []string) ([]map[string]string, error) {
+	pipe := c.client.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.HGetAll(ctx, key))
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	values := make([]map[string]string, 0, len(keys))
+	for _, cmd := range cmds {
+		values = append(values, cmd.Val())
+	}
+	return values, nil
+}
+
+func (c *redisCache) HDel(ctx context.Context, key string, field string) error {
+	err := c.client.HDel(ctx, key, field).Err()
+	return err
+}
+
+func (c *redisCache) HLen(ctx context.Context, key string) (int64, error) {
+	return c.client.HLen(ctx, key).Result()
+}
+
+func (c *redisCache) SAdd(ctx context.Context, key string, member string) (bool, error) {
+	added, err := c.client.SAdd(ctx, key, member).Result()
+	return added > 0, err
+}
+
+func (c *redisCache) SRem(ctx context.Context, key string, member string) (bool, error) {
+	removed, err := c.client.SRem(ctx, key, member).Result()
+	return removed > 0, err
+}
+
+func (c *redisCache) SMembers(ctx context.Context, key string) ([]string, error) {
+	val, err := c.client.SMembers(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (c *redisCache) ListPush(ctx context.Context, key string, value string, maxLen int64, ttl time.Duration) error {
+	pipe := c.client.TxPipeline()
+	pipe.RPush(ctx, key, value)
+	if maxLen > 0 {
+		pipe.LTrim(ctx, key, -maxLen, -1)
+	}
+	if ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// listPushSequencedScript increments the counter and pushes the item within a single command, so no
+// other push can take a count between them and the list never lags behind the counter.
+var listPushSequencedScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+redis.call("RPUSH", KEYS[2], count .. ":" .. ARGV[1])
+
+local maxLen = tonumber(ARGV[2])
+if maxLen > 0 then
+	redis.call("LTRIM", KEYS[2], -maxLen, -1)
+end
+
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call("PEXPIRE", KEYS[2], ttl)
+end
+return count
+`)
+
+func (c *redisCache) ListPushSequenced(ctx context.Context, counterKey string, key string, value string, maxLen int64, ttl time.Duration) (int64, error) {
+	return listPushSequencedScript.Run(ctx, c.client, []string{counterKey, key}, value, maxLen, ttl.Milliseconds()).Int64()
+}
+
+func (c *redisCache) ListRange(ctx context.Context, key string) ([]string, error) {
+	val, err := c.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (c *redisCache) ListPopAll(ctx context.Context, key string) ([]string, error) {
+	pipe := c.client.TxPipeline()
+	values := pipe.LRange(ctx, key, 0, -1)
+	pipe.Del(ctx, key)
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	return values.Val(), nil
+}
+
+// takeTokenScript refills the bucket for the time elapsed since it was last used, by the clock of
+// the redis server so every pod agrees, and takes a token when there is one.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated_at")
+local tokens = tonumber(bucket[1]) or burst
+local updatedAt = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updatedAt) * rate)
+
+local allowed = 0
+local retryAfter = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retryAfter = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated_at", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+return {allowed, retryAfter}
+`)
+
+func (c *redisCache) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, time.Duration, error) {
+	// The script works in milliseconds.
+	val, err := takeTokenScript.Run(ctx, c.client, []string{key}, rate/1000, burst).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return val[0] == 1, time.Duration(val[1]) * time.Millisecond, nil
+}
@@ -23,10 +23,23 @@ func NewRedisSubscriber(config *redisConnectionConfig) pubsubconnector.Subscribe
 
 func (redisSubscriber *redisSubscriber) SubscribeAsync(ctx context.Context, topic string, eventsChan chan []byte) {
 	pubsub := redisSubscriber.client.Subscribe(ctx, topic)
+	defer pubsub.Close()
 
 	ch := pubsub.Channel()
 
-	for msg := range ch {
-		eventsChan <- []byte(msg.Payload)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			select {
+			case eventsChan <- []byte(msg.Payload):
+			case <-ctx.Done():
+				return
+			}
+		}
 	}
 }
@@ -12,6 +12,9 @@ const (
 	ConnectionClosed                         = "websocket_handler: connection closed"
 	FailedToCreateHttpClient                 = "Failed to create http client"
 	FailedToStartServer                      = "Failed to start server"
+	FailedToShutdownServer                   = "Failed to shutdown server"
+	FailedToWaitInFlightEvents               = "Failed to wait for in-flight events"
+	ShuttingDown                             = "Shutting down, draining connections"
 	FailedToLoadEnvVars                      = "Failed to load environment variables"
 	FailedToReadMessageFromWebsocket         = "websocket_handler: failed to read message from webSocket client"
 	FailedToUnmarshalMessage                 = "websocket_handler: failed to unmarshal message"
@@ -19,4 +22,22 @@ const (
 	FailedToPublishMessageToPubSubBroker     = "websocket_handler: failed to publish message to pubsub broker"
 	PublishMessageToPubSubBrokerSuccessfully = "websocket_handler: message type %s published successfully\n"
 	ErrorToInitInstrumentation               = "error to initialize instrumentation"
+	FailedToLookupUserPod                    = "websocket_handler: failed to lookup user pod"
+	FailedToSendEventToUser                  = "websocket_handler: failed to send event to user_id"
+	FailedToTrackDelivery                    = "websocket_handler: failed to track event delivery"
+	FailedToLoadPendingDeliveries            = "websocket_handler: failed to load pending deliveries"
+	FailedToStoreOfflineEvent                = "websocket_handler: failed to store event in offline inbox"
+	FailedToDrainOfflineInbox                = "websocket_handler: failed to drain offline inbox"
+	FailedToSequenceEvent                    = "websocket_handler: failed to assign event sequence"
+	FailedToResumeSession                    = "websocket_handler: failed to resume session"
+	FailedToNotifyPresence                   = "websocket_handler: failed to notify presence"
+	FailedToCreateChannel                    = "websocket_handler: failed to create channel"
+	FailedToRequeueUser                      = "websocket_handler: failed to requeue user"
+	FailedToCloseProposal                    = "websocket_handler: failed to close channel proposal"
+	FailedToRejectProposal                   = "websocket_handler: failed to reject channel proposal"
+	FailedToSearchChannel                    = "websocket_handler: failed to search channel"
+	FailedToHandleEvent                      = "websocket_handler: failed to handle event"
+	FailedToRateLimitEvent                   = "websocket_handler: failed to rate limit event"
+	FailedToAuthenticate                     = "websocket_handler: failed to authenticate connection"
+	FailedToUpdateReceipts                   = "websocket_handler: failed to update receipts"
 )